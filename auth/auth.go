@@ -0,0 +1,65 @@
+/*
+Package auth provides the pieces that Gadget applications need to log users in
+with a username and password: password hashing with upgradeable parameters,
+Login and Logout helpers that keep the user's ID in the session, and a
+LoginForm that checks credentials and throttles repeated failures.
+
+A typical setup identifies users from the session:
+
+	gadget.StoreSessionsWith(session.NewCookieStore(key))
+	gadget.IdentifyUsersWith(auth.SessionUser(func(id interface{}) gadget.User {
+		return models.FindUser(id.(int))
+	}))
+
+and logs them in from a controller:
+
+	func (c *SessionsController) Create(r *gadget.Request) (int, interface{}) {
+		form := auth.NewLoginForm()
+		user, err := form.Authenticate(r, func(username string) auth.Account {
+			return models.FindUserByEmail(username)
+		})
+		if err != nil {
+			return 422, form
+		}
+		auth.Login(r, user, user.(*models.User).Id)
+		return 303, "/"
+	}
+
+Both require sessions to be configured with gadget.StoreSessionsWith.
+*/
+package auth
+
+import (
+	"github.com/redneckbeard/gadget"
+)
+
+// SessionKey is the session key under which Login stores the user's ID.
+var SessionKey = "user_id"
+
+// Login records user as logged in for the rest of the request and, through
+// the session, for later requests. id is what SessionUser's lookup will be
+// given to find the user again, so it must be a type the session store can
+// encode. The session is rotated so that a session ID planted before login
+// can't be used to hijack it afterward.
+func Login(r *gadget.Request, user gadget.User, id interface{}) {
+	sess := r.Session()
+	sess.Rotate()
+	sess.Set(SessionKey, id)
+	r.SetUser(user)
+}
+
+// Logout clears the session and rotates its ID, leaving an AnonymousUser for
+// the rest of the request. The session itself is kept so that flash messages
+// set after logging out still reach the client.
+func Logout(r *gadget.Request) {
+	sess := r.Session()
+	sess.Clear()
+	sess.Rotate()
+	r.SetUser(&gadget.AnonymousUser{})
+}
+
+// SessionUser returns a UserIdentifier that finds the user logged in with
+// Login, calling lookup with the ID stored under SessionKey.
+func SessionUser(lookup func(id interface{}) gadget.User) gadget.UserIdentifier {
+	return gadget.SessionUser(SessionKey, lookup)
+}
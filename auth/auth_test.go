@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"github.com/redneckbeard/gadget"
+	"github.com/redneckbeard/gadget/session"
+	. "launchpad.net/gocheck"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AuthSuite struct{}
+
+var _ = Suite(&AuthSuite{})
+
+var (
+	cheapArgon2id = Argon2id{Time: 1, Memory: 64, Threads: 1, KeyLen: 16}
+	cheapScrypt   = Scrypt{N: 16, R: 1, P: 1, KeyLen: 16}
+	cheapBcrypt   = Bcrypt{Cost: 4}
+)
+
+func (s *AuthSuite) SetUpTest(c *C) {
+	HashPasswordsWith(cheapArgon2id)
+	gadget.StoreSessionsWith(session.NewMemoryStore())
+}
+
+func (s *AuthSuite) TearDownTest(c *C) {
+	HashPasswordsWith(KnownHashers[0])
+	ThrottleLoginsWith(nil)
+	gadget.StoreSessionsWith(nil)
+}
+
+type account struct {
+	name, hash string
+	upgraded   bool
+}
+
+func (a *account) Authenticated() bool  { return true }
+func (a *account) PasswordHash() string { return a.hash }
+func (a *account) SetPasswordHash(hash string) {
+	a.hash = hash
+	a.upgraded = true
+}
+
+func newAccount(c *C, name, password string) *account {
+	hash, err := HashPassword(password)
+	c.Assert(err, IsNil)
+	return &account{name: name, hash: hash}
+}
+
+func loginRequest(username, password string) *gadget.Request {
+	return gadget.RequestFor(httptest.NewRequest("POST", "/sessions?Username="+username+"&Password="+password, nil))
+}
+
+func lookupIn(accounts ...*account) func(string) Account {
+	return func(username string) Account {
+		for _, a := range accounts {
+			if a.name == username {
+				return a
+			}
+		}
+		return nil
+	}
+}
+
+//Each Hasher should verify the passwords it hashes and reject others
+func (s *AuthSuite) TestEachHasherVerifiesPasswordsItHashes(c *C) {
+	for _, h := range []Hasher{cheapArgon2id, cheapScrypt, cheapBcrypt} {
+		hash, err := h.Hash("hunter2")
+		c.Assert(err, IsNil)
+		c.Assert(h.Recognizes(hash), Equals, true)
+		ok, current := h.Verify("hunter2", hash)
+		c.Assert(ok, Equals, true)
+		c.Assert(current, Equals, true)
+		ok, _ = h.Verify("hunter3", hash)
+		c.Assert(ok, Equals, false)
+	}
+}
+
+//CheckPassword should ask for a rehash when the Hasher's parameters have changed
+func (s *AuthSuite) TestCheckpasswordAsksForRehashWhenParametersChange(c *C) {
+	hash, _ := HashPassword("hunter2")
+	ok, rehash := CheckPassword("hunter2", hash)
+	c.Assert(ok, Equals, true)
+	c.Assert(rehash, Equals, false)
+	HashPasswordsWith(Argon2id{Time: 2, Memory: 64, Threads: 1, KeyLen: 16})
+	ok, rehash = CheckPassword("hunter2", hash)
+	c.Assert(ok, Equals, true)
+	c.Assert(rehash, Equals, true)
+}
+
+//CheckPassword should verify hashes from other known schemes and ask for a rehash
+func (s *AuthSuite) TestCheckpasswordVerifiesOtherKnownSchemes(c *C) {
+	for _, h := range []Hasher{cheapScrypt, cheapBcrypt} {
+		hash, _ := h.Hash("hunter2")
+		ok, rehash := CheckPassword("hunter2", hash)
+		c.Assert(ok, Equals, true)
+		c.Assert(rehash, Equals, true)
+		ok, rehash = CheckPassword("hunter3", hash)
+		c.Assert(ok, Equals, false)
+		c.Assert(rehash, Equals, false)
+	}
+}
+
+//CheckPassword should reject malformed and unknown hashes
+func (s *AuthSuite) TestCheckpasswordRejectsMalformedHashes(c *C) {
+	for _, hash := range []string{"", "hunter2", "$argon2id$v=19$m=64,t=1,p=1$", "$scrypt$ln=4,r=1,p=1$!!$!!", "$md5$abc"} {
+		ok, _ := CheckPassword("hunter2", hash)
+		c.Assert(ok, Equals, false)
+	}
+}
+
+//Login should store the user's id in the session and set the request's User
+func (s *AuthSuite) TestLoginStoresIdInSessionAndSetsUser(c *C) {
+	r := loginRequest("bob", "hunter2")
+	user := &account{name: "bob"}
+	Login(r, user, 7)
+	c.Assert(r.User(), Equals, gadget.User(user))
+	c.Assert(r.Session().Get(SessionKey), Equals, 7)
+	c.Assert(r.Session().Dirty(), Equals, true)
+}
+
+//Logout should clear the session and leave an anonymous user
+func (s *AuthSuite) TestLogoutClearsSessionAndLeavesAnonymousUser(c *C) {
+	r := loginRequest("bob", "hunter2")
+	Login(r, &account{name: "bob"}, 7)
+	Logout(r)
+	c.Assert(r.User().Authenticated(), Equals, false)
+	c.Assert(r.Session().Get(SessionKey), IsNil)
+	c.Assert(r.Session().Destroyed(), Equals, false)
+}
+
+//Authenticate should return the account when the password matches
+func (s *AuthSuite) TestAuthenticateReturnsAccountWhenPasswordMatches(c *C) {
+	bob := newAccount(c, "bob", "hunter2")
+	form := NewLoginForm()
+	user, err := form.Authenticate(loginRequest("bob", "hunter2"), lookupIn(bob))
+	c.Assert(err, IsNil)
+	c.Assert(user, Equals, gadget.User(bob))
+	c.Assert(form.HasErrors(), Equals, false)
+}
+
+//Authenticate should return ErrInvalidLogin for wrong passwords and unknown users
+func (s *AuthSuite) TestAuthenticateRejectsWrongPasswordsAndUnknownUsers(c *C) {
+	bob := newAccount(c, "bob", "hunter2")
+	for _, creds := range [][2]string{{"bob", "hunter3"}, {"alice", "hunter2"}} {
+		form := NewLoginForm()
+		user, err := form.Authenticate(loginRequest(creds[0], creds[1]), lookupIn(bob))
+		c.Assert(user, IsNil)
+		c.Assert(err, Equals, ErrInvalidLogin)
+		c.Assert(form.Errors["Password"], Equals, ErrInvalidLogin)
+	}
+}
+
+//Authenticate should reject incomplete forms
+func (s *AuthSuite) TestAuthenticateRejectsIncompleteForms(c *C) {
+	form := NewLoginForm()
+	r := gadget.RequestFor(httptest.NewRequest("POST", "/sessions?Username=bob", nil))
+	_, err := form.Authenticate(r, lookupIn())
+	c.Assert(err, Equals, ErrInvalidLogin)
+	c.Assert(form.Errors["Password"], ErrorMatches, "This field is required")
+}
+
+//Authenticate should upgrade the hashes of accounts that can be rehashed
+func (s *AuthSuite) TestAuthenticateUpgradesOutdatedHashes(c *C) {
+	hash, _ := cheapBcrypt.Hash("hunter2")
+	bob := &account{name: "bob", hash: hash}
+	_, err := NewLoginForm().Authenticate(loginRequest("bob", "hunter2"), lookupIn(bob))
+	c.Assert(err, IsNil)
+	c.Assert(bob.upgraded, Equals, true)
+	c.Assert(cheapArgon2id.Recognizes(bob.hash), Equals, true)
+}
+
+//Authenticate should refuse attempts once the Throttle's limit is reached
+func (s *AuthSuite) TestAuthenticateRefusesAttemptsOnceThrottled(c *C) {
+	ThrottleLoginsWith(NewMemoryThrottle(2, time.Minute))
+	bob := newAccount(c, "bob", "hunter2")
+	for i := 0; i < 2; i++ {
+		_, err := NewLoginForm().Authenticate(loginRequest("bob", "wrong"), lookupIn(bob))
+		c.Assert(err, Equals, ErrInvalidLogin)
+	}
+	form := NewLoginForm()
+	_, err := form.Authenticate(loginRequest("bob", "hunter2"), lookupIn(bob))
+	throttled, ok := err.(*ThrottledError)
+	c.Assert(ok, Equals, true)
+	c.Assert(throttled.RetryAfter > 0, Equals, true)
+	c.Assert(form.Errors["Username"], Equals, err)
+}
+
+//A successful login should reset the MemoryThrottle's count of failures
+func (s *AuthSuite) TestSuccessfulLoginResetsMemorythrottle(c *C) {
+	ThrottleLoginsWith(NewMemoryThrottle(2, time.Minute))
+	bob := newAccount(c, "bob", "hunter2")
+	NewLoginForm().Authenticate(loginRequest("bob", "wrong"), lookupIn(bob))
+	_, err := NewLoginForm().Authenticate(loginRequest("bob", "hunter2"), lookupIn(bob))
+	c.Assert(err, IsNil)
+	NewLoginForm().Authenticate(loginRequest("bob", "wrong"), lookupIn(bob))
+	_, err = NewLoginForm().Authenticate(loginRequest("bob", "hunter2"), lookupIn(bob))
+	c.Assert(err, IsNil)
+}
+
+//A MemoryThrottle should allow attempts again once its window has passed
+func (s *AuthSuite) TestMemorythrottleAllowsAttemptsAfterWindow(c *C) {
+	t := NewMemoryThrottle(1, 10*time.Millisecond)
+	t.Failed("bob")
+	ok, _ := t.Allow("bob")
+	c.Assert(ok, Equals, false)
+	time.Sleep(20 * time.Millisecond)
+	ok, _ = t.Allow("bob")
+	c.Assert(ok, Equals, true)
+}
+
+//ThrottleKey should use the client's address from a trusted proxy
+func (s *AuthSuite) TestThrottlekeyUsesClientAddressFromTrustedProxy(c *C) {
+	gadget.TrustProxies("10.0.0.1")
+	defer gadget.TrustProxies()
+	r := loginRequest("Bob", "wrong")
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	c.Assert(ThrottleKey(r, "Bob"), Equals, "bob|203.0.113.9")
+	r.Header.Set("X-Forwarded-For", "198.51.100.4")
+	c.Assert(ThrottleKey(r, "Bob"), Equals, "bob|198.51.100.4")
+}
+
+//A MemoryThrottle should discard expired entries for keys that aren't tried again
+func (s *AuthSuite) TestMemorythrottleDiscardsExpiredEntries(c *C) {
+	t := NewMemoryThrottle(1, 10*time.Millisecond)
+	for _, key := range []string{"a|1", "b|2", "c|3"} {
+		t.Failed(key)
+	}
+	c.Assert(len(t.entries), Equals, 3)
+	time.Sleep(20 * time.Millisecond)
+	t.Failed("d|4")
+	c.Assert(len(t.entries), Equals, 1)
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redneckbeard/gadget"
+	"github.com/redneckbeard/gadget/forms"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidLogin is returned by LoginForm.Authenticate when the form is
+// incomplete or the username and password don't match an Account.
+var ErrInvalidLogin = errors.New("Invalid username or password")
+
+// dummy holds a hash made with the current Hasher that is checked against when
+// no Account is found, so that failed logins take as long for unknown
+// usernames as for known ones.
+var dummy struct {
+	sync.Mutex
+	hash string
+}
+
+func dummyHash() string {
+	dummy.Lock()
+	defer dummy.Unlock()
+	if dummy.hash == "" {
+		dummy.hash, _ = hasher.Hash("")
+	}
+	return dummy.hash
+}
+
+// ThrottledError is returned by LoginForm.Authenticate when the Throttle
+// refuses a login attempt. RetryAfter is how long the client should wait
+// before trying again.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("Too many failed login attempts; try again in %s", e.RetryAfter)
+}
+
+// Account is the interface that users who log in with a password implement.
+// Accounts that also have a SetPasswordHash(string) method have their hashes
+// upgraded automatically when CheckPassword reports that they need
+// rehashing; the application is responsible for persisting the change.
+type Account interface {
+	gadget.User
+	PasswordHash() string
+}
+
+type rehashable interface {
+	SetPasswordHash(hash string)
+}
+
+// Throttle is the interface for limiting repeated login attempts. Allow is
+// called with the key for an attempt before the password is checked, and
+// returns false along with how long to wait if the attempt should be refused.
+// Failed and Succeeded are called after the password has been checked, so
+// that implementations can count failures and reset the count on success.
+type Throttle interface {
+	Allow(key string) (ok bool, retryAfter time.Duration)
+	Failed(key string)
+	Succeeded(key string)
+}
+
+var throttle Throttle
+
+// ThrottleLoginsWith sets the Throttle that LoginForm.Authenticate consults.
+// Logins are not throttled by default.
+//
+// 	auth.ThrottleLoginsWith(auth.NewMemoryThrottle(5, 15*time.Minute))
+func ThrottleLoginsWith(t Throttle) {
+	throttle = t
+}
+
+// ThrottleKey returns the key that login attempts are throttled by. By
+// default, it combines the lowercased username with the client's IP address,
+// so that one client guessing at an account doesn't lock out its owner
+// everywhere. Behind a reverse proxy, the proxy must be trusted with
+// gadget.TrustProxies for the client's address to be used.
+var ThrottleKey = func(r *gadget.Request, username string) string {
+	return strings.ToLower(username) + "|" + r.ClientIP()
+}
+
+// LoginForm validates a username and password submitted as the Username and
+// Password params.
+type LoginForm struct {
+	*forms.DefaultForm
+	Username *forms.StringField `required:"true"`
+	Password *forms.StringField `required:"true"`
+}
+
+// NewLoginForm returns an initialized LoginForm.
+func NewLoginForm() *LoginForm {
+	form := &LoginForm{}
+	forms.Init(form, nil)
+	return form
+}
+
+// Authenticate populates the form from the request's Params and returns the
+// Account that lookup finds for the username if the password matches it.
+// lookup should return nil if there is no such account. If the form is
+// incomplete or the credentials don't match, Authenticate returns
+// ErrInvalidLogin and records the error on the form. If the Throttle refuses
+// the attempt, it returns a *ThrottledError without checking the password.
+// Authenticate doesn't log the user in; call Login with the result.
+func (f *LoginForm) Authenticate(r *gadget.Request, lookup func(username string) Account) (gadget.User, error) {
+	forms.Populate(f, r.Params)
+	if !forms.IsValid(f) {
+		return nil, ErrInvalidLogin
+	}
+	username, password := f.Username.Value, f.Password.Value
+	key := ThrottleKey(r, username)
+	if throttle != nil {
+		if ok, retryAfter := throttle.Allow(key); !ok {
+			err := &ThrottledError{RetryAfter: retryAfter}
+			forms.SetErrors(f, map[string]error{"Username": err})
+			return nil, err
+		}
+	}
+	var hash string
+	account := lookup(username)
+	if account != nil {
+		hash = account.PasswordHash()
+	} else {
+		hash = dummyHash()
+	}
+	ok, rehash := CheckPassword(password, hash)
+	if !ok || account == nil {
+		if throttle != nil {
+			throttle.Failed(key)
+		}
+		forms.SetErrors(f, map[string]error{"Password": ErrInvalidLogin})
+		return nil, ErrInvalidLogin
+	}
+	if throttle != nil {
+		throttle.Succeeded(key)
+	}
+	if settable, ok := account.(rehashable); ok && rehash {
+		if hash, err := HashPassword(password); err == nil {
+			settable.SetPasswordHash(hash)
+		}
+	}
+	return account, nil
+}
+
+type throttleEntry struct {
+	failures int
+	reset    time.Time
+}
+
+// MemoryThrottle is a Throttle that refuses attempts for a key once it has
+// failed Limit times within Window, until Window has passed since the first of
+// those failures. Counts are kept in memory, so they aren't shared between
+// processes.
+type MemoryThrottle struct {
+	Limit     int
+	Window    time.Duration
+	mu        sync.Mutex
+	entries   map[string]*throttleEntry
+	lastSweep time.Time
+}
+
+// NewMemoryThrottle returns a MemoryThrottle allowing limit failures per
+// window.
+func NewMemoryThrottle(limit int, window time.Duration) *MemoryThrottle {
+	return &MemoryThrottle{Limit: limit, Window: window, entries: make(map[string]*throttleEntry)}
+}
+
+func (t *MemoryThrottle) Allow(key string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := t.current(key)
+	if entry == nil || entry.failures < t.Limit {
+		return true, 0
+	}
+	return false, entry.reset.Sub(time.Now())
+}
+
+func (t *MemoryThrottle) Failed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(time.Now())
+	entry := t.current(key)
+	if entry == nil {
+		entry = &throttleEntry{reset: time.Now().Add(t.Window)}
+		t.entries[key] = entry
+	}
+	entry.failures++
+}
+
+func (t *MemoryThrottle) Succeeded(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// sweep discards entries whose windows have passed, at most once per Window, so
+// that keys which are never tried again don't stay in memory.
+func (t *MemoryThrottle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.Window {
+		return
+	}
+	t.lastSweep = now
+	for key, entry := range t.entries {
+		if now.After(entry.reset) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+// current returns the entry for key, discarding it if its window has passed.
+func (t *MemoryThrottle) current(key string) *throttleEntry {
+	entry, ok := t.entries[key]
+	if ok && time.Now().After(entry.reset) {
+		delete(t.entries, key)
+		return nil
+	}
+	return entry
+}
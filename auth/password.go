@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+	"strings"
+)
+
+// Hasher is the interface implemented by password hashing schemes. Hash
+// returns an encoded hash that records the scheme and its parameters along
+// with the salt, so that the hash can be verified after the parameters have
+// changed. Recognizes reports whether an encoded hash was produced by the
+// scheme, and Verify reports whether password matches it and whether it was
+// hashed with the Hasher's current parameters.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Recognizes(hash string) bool
+	Verify(password, hash string) (ok, current bool)
+}
+
+// Bcrypt hashes passwords with bcrypt at the given Cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b Bcrypt) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b Bcrypt) Verify(password, hash string) (ok, current bool) {
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err == nil && cost == b.Cost
+}
+
+// Scrypt hashes passwords with scrypt. N must be a power of two greater than
+// one. Hashes are encoded as $scrypt$ln=<log2 N>,r=<R>,p=<P>$<salt>$<key>.
+type Scrypt struct {
+	N, R, P, KeyLen int
+}
+
+func (s Scrypt) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), salt, s.N, s.R, s.P, s.KeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", log2(s.N), s.R, s.P, b64(salt), b64(key)), nil
+}
+
+func (s Scrypt) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$scrypt$")
+}
+
+func (s Scrypt) Verify(password, hash string) (ok, current bool) {
+	var ln, r, p int
+	fields, salt, key, err := splitHash(hash, "scrypt", 3)
+	if err != nil {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(fields[0], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil || ln < 1 || ln > 30 {
+		return false, false
+	}
+	derived, err := scrypt.Key([]byte(password), salt, 1<<uint(ln), r, p, len(key))
+	if err != nil || subtle.ConstantTimeCompare(derived, key) != 1 {
+		return false, false
+	}
+	return true, 1<<uint(ln) == s.N && r == s.R && p == s.P && len(key) == s.KeyLen
+}
+
+// Argon2id hashes passwords with Argon2id, using Time passes over Memory KiB
+// with Threads lanes. Hashes are encoded in the PHC string format used by the
+// reference implementation: $argon2id$v=19$m=<Memory>,t=<Time>,p=<Threads>$<salt>$<key>.
+type Argon2id struct {
+	Time, Memory uint32
+	Threads      uint8
+	KeyLen       uint32
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Time, a.Threads, b64(salt), b64(key)), nil
+}
+
+func (a Argon2id) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (a Argon2id) Verify(password, hash string) (ok, current bool) {
+	var memory, time uint32
+	var threads uint8
+	fields, salt, key, err := splitHash(hash, "argon2id", 4)
+	if err != nil || fields[0] != fmt.Sprintf("v=%d", argon2.Version) {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(fields[1], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil || time == 0 || threads == 0 {
+		return false, false
+	}
+	derived := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return false, false
+	}
+	return true, memory == a.Memory && time == a.Time && threads == a.Threads && uint32(len(key)) == a.KeyLen
+}
+
+var (
+	hasher Hasher = Argon2id{Time: 3, Memory: 64 * 1024, Threads: 2, KeyLen: 32}
+	// KnownHashers are the schemes that CheckPassword falls back to for
+	// hashes not produced by the Hasher set with HashPasswordsWith. Hashes
+	// verified by one of them always need rehashing.
+	KnownHashers = []Hasher{
+		Argon2id{Time: 3, Memory: 64 * 1024, Threads: 2, KeyLen: 32},
+		Scrypt{N: 1 << 15, R: 8, P: 1, KeyLen: 32},
+		Bcrypt{Cost: bcrypt.DefaultCost},
+	}
+)
+
+// HashPasswordsWith sets the Hasher used by HashPassword. The default is
+// Argon2id with 3 passes over 64 MiB on 2 threads. Hashes made with another
+// Hasher, or with different parameters, continue to verify, and CheckPassword
+// reports that they should be replaced.
+//
+// 	auth.HashPasswordsWith(auth.Bcrypt{Cost: 12})
+func HashPasswordsWith(h Hasher) {
+	hasher = h
+	dummy.Lock()
+	dummy.hash = ""
+	dummy.Unlock()
+}
+
+// HashPassword hashes password with the current Hasher.
+func HashPassword(password string) (string, error) {
+	return hasher.Hash(password)
+}
+
+// CheckPassword reports whether password matches hash, and whether hash should
+// be replaced with the result of HashPassword because it was made with a
+// different Hasher or different parameters than are now in use. Applications
+// should store the new hash when rehash is true, since that is the only time
+// the plaintext password is available.
+//
+// 	if ok, rehash := auth.CheckPassword(password, user.PasswordHash); ok && rehash {
+// 		user.PasswordHash, _ = auth.HashPassword(password)
+// 		user.Save()
+// 	}
+func CheckPassword(password, hash string) (ok, rehash bool) {
+	if hasher.Recognizes(hash) {
+		ok, current := hasher.Verify(password, hash)
+		return ok, ok && !current
+	}
+	for _, h := range KnownHashers {
+		if h.Recognizes(hash) {
+			ok, _ := h.Verify(password, hash)
+			return ok, ok
+		}
+	}
+	return false, false
+}
+
+// splitHash splits a PHC-style hash of the form $scheme$...$salt$key with n
+// fields after the scheme, returning the fields along with the decoded salt
+// and key.
+func splitHash(hash, scheme string, n int) (fields []string, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != n+2 || parts[0] != "" || parts[1] != scheme {
+		return nil, nil, nil, fmt.Errorf("auth: malformed %s hash", scheme)
+	}
+	fields = parts[2:]
+	if salt, err = base64.RawStdEncoding.DecodeString(fields[n-2]); err != nil {
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(fields[n-1]); err != nil {
+		return
+	}
+	if len(key) == 0 {
+		err = fmt.Errorf("auth: malformed %s hash", scheme)
+	}
+	return
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+func b64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func log2(n int) (ln int) {
+	for n > 1 {
+		n >>= 1
+		ln++
+	}
+	return
+}
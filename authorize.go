@@ -0,0 +1,162 @@
+package gadget
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permissions is an optional interface for Users that can be granted
+// permissions directly, such as "posts.edit".
+type Permissions interface {
+	HasPermission(permission string) bool
+}
+
+// Roles is an optional interface for Users that have roles. Roles can be
+// granted permissions with GrantRole.
+type Roles interface {
+	HasRole(role string) bool
+}
+
+var rolePermissions = make(map[string][]string)
+
+// GrantRole gives every User with role the named permissions.
+//
+// 	gadget.GrantRole("editor", "posts.edit", "posts.publish")
+func GrantRole(role string, permissions ...string) {
+	rolePermissions[role] = append(rolePermissions[role], permissions...)
+}
+
+// Can reports whether user has permission, either directly through the
+// Permissions interface or through a role granted it with GrantRole.
+// Unauthenticated users never have any permissions.
+func Can(user User, permission string) bool {
+	if user == nil || !user.Authenticated() {
+		return false
+	}
+	if p, ok := user.(Permissions); ok && p.HasPermission(permission) {
+		return true
+	}
+	if roles, ok := user.(Roles); ok {
+		for role, permissions := range rolePermissions {
+			for _, p := range permissions {
+				if p == permission && roles.HasRole(role) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// HasRole reports whether user is authenticated and has role.
+func HasRole(user User, role string) bool {
+	if user == nil || !user.Authenticated() {
+		return false
+	}
+	roles, ok := user.(Roles)
+	return ok && roles.HasRole(role)
+}
+
+// HasRole reports whether the "roles" claim of the Identity includes role.
+func (i *Identity) HasRole(role string) bool {
+	return claimIncludes(i.Claims["roles"], role)
+}
+
+// HasPermission reports whether the "permissions" claim of the Identity
+// includes permission, or its space-separated "scope" claim does.
+func (i *Identity) HasPermission(permission string) bool {
+	if claimIncludes(i.Claims["permissions"], permission) {
+		return true
+	}
+	scope, _ := i.Claims["scope"].(string)
+	return claimIncludes(strings.Fields(scope), permission)
+}
+
+func claimIncludes(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []string:
+		for _, c := range claim {
+			if c == value {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, c := range claim {
+			if c == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuthChallenge, if set, is sent as the WWW-Authenticate header of the 401
+// responses to unauthenticated requests for actions with authorization rules,
+// for example `Basic realm="Gadget"` or "Bearer".
+var AuthChallenge = ""
+
+// Require allows only users with permission (see Can) to reach the named
+// actions.
+//
+// 	c := &PostController{}
+// 	gadget.Register(c)
+// 	c.Require("posts.edit", "update", "destroy")
+func (c *DefaultController) Require(permission string, actions ...string) {
+	c.Authorize(func(r *Request) bool { return Can(r.User(), permission) }, actions...)
+}
+
+// RequireRole allows only users with role to reach the named actions.
+func (c *DefaultController) RequireRole(role string, actions ...string) {
+	c.Authorize(func(r *Request) bool { return HasRole(r.User(), role) }, actions...)
+}
+
+// Authorize adds a rule that must return true for a request to reach the
+// named actions. Rules run after the UrlParams are set, but before any
+// Filters, so they can check access to the
+// particular resource requested:
+//
+// 	c.Authorize(func(r *gadget.Request) bool {
+// 		post := models.FindPost(r.UrlParams["post_id"])
+// 		return post.AuthorId == r.User().(*models.User).Id || gadget.Can(r.User(), "posts.edit")
+// 	}, "update", "destroy")
+//
+// Requests for actions with rules are answered with a 401 Unauthorized if the
+// user is not authenticated, and a 403 Forbidden if any rule returns false.
+func (c *DefaultController) Authorize(rule func(r *Request) bool, actions ...string) {
+	if c.filters == nil {
+		panic("Calls to Authorize, Require, and RequireRole must be made after a controller is registered")
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to add authorization rule for '%s' -- no such action", action))
+		}
+		c.rules[action] = append(c.rules[action], rule)
+	}
+}
+
+func (c *DefaultController) authorize(r *Request, action string) (int, interface{}) {
+	rules := c.rules[action]
+	if len(rules) == 0 {
+		return 0, nil
+	}
+	if !r.User().Authenticated() {
+		return unauthorized()
+	}
+	for _, rule := range rules {
+		if !rule(r) {
+			return 403, ""
+		}
+	}
+	return 0, nil
+}
+
+func unauthorized() (int, interface{}) {
+	if AuthChallenge == "" {
+		return 401, ""
+	}
+	response := NewResponse("")
+	response.Headers.Set("WWW-Authenticate", AuthChallenge)
+	return 401, response
+}
@@ -0,0 +1,109 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type AuthorizeSuite struct{}
+
+type authorizeApp struct {
+	*App
+}
+
+var za *authorizeApp
+
+var _ = Suite(&AuthorizeSuite{})
+
+func (s *AuthorizeSuite) SetUpTest(c *C) {
+	za = &authorizeApp{&App{}}
+	ctlr := &MemoController{}
+	za.Register(ctlr)
+	ctlr.Require("memos.edit", "update", "destroy")
+	ctlr.RequireRole("admin", "destroy")
+	ctlr.Authorize(func(r *Request) bool {
+		return r.UrlParams["memo_id"] != "13"
+	}, "update")
+	za.Accept("application/json").Via(JsonBroker)
+	za.Routes(za.Resource("memos"))
+	GrantRole("editor", "memos.edit")
+	IdentifyUsersWith(func(r *Request) User {
+		switch r.Header.Get("X-User") {
+		case "editor":
+			return &Identity{Id: "ed", Claims: map[string]interface{}{"roles": []interface{}{"editor"}}}
+		case "admin":
+			return &Identity{Id: "ad", Claims: map[string]interface{}{"roles": []interface{}{"admin"}, "scope": "memos.read memos.edit"}}
+		case "reader":
+			return &Identity{Id: "re"}
+		}
+		return nil
+	})
+}
+
+func (s *AuthorizeSuite) TearDownTest(c *C) {
+	za.Controllers = make(map[string]Controller)
+	rolePermissions = make(map[string][]string)
+	AuthChallenge = ""
+	clearUserIdentifier()
+}
+
+type MemoController struct {
+	*DefaultController
+}
+
+func (c *MemoController) Show(r *Request) (int, interface{}) { return 200, "memo" }
+
+func (c *MemoController) Update(r *Request) (int, interface{}) { return 200, "updated" }
+
+func (c *MemoController) Destroy(r *Request) (int, interface{}) { return 204, "" }
+
+func (s *AuthorizeSuite) do(method, path, user string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/"+path, nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	resp := httptest.NewRecorder()
+	za.Handler()(resp, req)
+	return resp
+}
+
+//Actions without rules are open to everyone
+func (s *AuthorizeSuite) TestUnrestricted(c *C) {
+	c.Assert(s.do("GET", "memos/1", "").Code, Equals, 200)
+}
+
+//Unauthenticated users get a 401 and authenticated ones without permission a 403
+func (s *AuthorizeSuite) TestUnauthorizedAndForbidden(c *C) {
+	c.Assert(s.do("PUT", "memos/1", "").Code, Equals, 401)
+	c.Assert(s.do("PUT", "memos/1", "reader").Code, Equals, 403)
+}
+
+//Permissions can come from roles or directly from the User
+func (s *AuthorizeSuite) TestPermissions(c *C) {
+	c.Assert(s.do("PUT", "memos/1", "editor").Code, Equals, 200)
+	c.Assert(s.do("PUT", "memos/1", "admin").Code, Equals, 200)
+}
+
+//Every rule for an action must pass
+func (s *AuthorizeSuite) TestRoles(c *C) {
+	c.Assert(s.do("DELETE", "memos/1", "editor").Code, Equals, 403)
+	c.Assert(s.do("DELETE", "memos/1", "admin").Code, Equals, 204)
+}
+
+//Rules can inspect the resource being requested
+func (s *AuthorizeSuite) TestResourceRules(c *C) {
+	c.Assert(s.do("PUT", "memos/13", "editor").Code, Equals, 403)
+}
+
+//401s carry AuthChallenge, and become problems for JSON clients
+func (s *AuthorizeSuite) TestChallenge(c *C) {
+	AuthChallenge = `Basic realm="memos"`
+	req, _ := http.NewRequest("PUT", "http://127.0.0.1:8000/memos/1", nil)
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	za.Handler()(resp, req)
+	c.Assert(resp.Code, Equals, 401)
+	c.Assert(resp.Header().Get("WWW-Authenticate"), Equals, `Basic realm="memos"`)
+	c.Assert(resp.Body.String(), Matches, `.*"status":401.*`)
+}
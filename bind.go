@@ -0,0 +1,312 @@
+package gadget
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindErrors is returned by Bind when individual fields of the request body
+// could not be decoded. It maps the names of the fields of the destination
+// struct (joined with "." for nested structs) to the error for each, so it can
+// be handed to forms.SetErrors.
+type BindErrors map[string]error
+
+func (e BindErrors) Error() string {
+	var msgs []string
+	for name, err := range e {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, err))
+	}
+	sort.Strings(msgs)
+	return strings.Join(msgs, "; ")
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Bind decodes the body of the request into dst, which must be a pointer to a
+// struct. JSON and XML bodies are decoded with encoding/json and encoding/xml
+// and their struct tags. URL-encoded and multipart form bodies (and query
+// parameters) are matched to fields by their "form" tag, falling back to
+// their "json" tag and then the field name; fields of struct type are filled
+// from bracketed keys, so that a field tagged `form:"author"` with a nested
+// field tagged `form:"name"` is set from "author[name]".
+//
+// If only some fields could not be decoded, the error is a BindErrors.
+func (r *Request) Bind(dst interface{}) error {
+	return r.bind("", dst)
+}
+
+// BindParam is like Bind, but decodes only the value under key, as in
+// {"post": {"title": "..."}} for JSON or post[title]=... for forms. XML
+// documents have a single root element, so BindParam decodes the whole
+// document for them.
+func (r *Request) BindParam(key string, dst interface{}) error {
+	return r.bind(key, dst)
+}
+
+func (r *Request) bind(key string, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("Bind requires a pointer to a struct")
+	}
+	r.parseBody(MaxBodySize)
+	switch ct := r.contentType(); {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		return r.bindJson(key, v)
+	case ct == "application/xml" || ct == "text/xml" || strings.HasSuffix(ct, "+xml"):
+		raw, err := r.rawBody()
+		if err != nil {
+			return err
+		}
+		return xml.Unmarshal(raw, dst)
+	case ct == "" || ct == "application/x-www-form-urlencoded" || strings.HasPrefix(ct, "multipart/form-data"):
+		if r.Form == nil {
+			return errors.New("Request form could not be parsed")
+		}
+		errs := make(BindErrors)
+		bindForm(r.Form, key, "", v.Elem(), errs)
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	default:
+		return fmt.Errorf("Cannot bind request body of type %s", ct)
+	}
+}
+
+// rawBody returns the request body, reading it at most once and leaving a copy
+// in r.Body for later readers. JSON bodies have already been read by parseBody.
+// UserIdentifiers may call rawBody before any size limit has been applied, so
+// no more than MaxBodySize bytes are kept; a longer body is an error, and is
+// left unconsumed in r.Body for actions with a larger limit of their own.
+func (r *Request) rawBody() ([]byte, error) {
+	if r.RawJson != nil {
+		return r.RawJson, nil
+	}
+	if r.raw != nil || r.Body == nil {
+		return r.raw, nil
+	}
+	body := r.Body
+	raw, err := ioutil.ReadAll(io.LimitReader(body, MaxBodySize+1))
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if int64(len(raw)) > MaxBodySize {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), body), body}
+		return nil, &http.MaxBytesError{Limit: MaxBodySize}
+	}
+	body.Close()
+	r.raw = raw
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	return raw, nil
+}
+
+func (r *Request) bindJson(key string, v reflect.Value) error {
+	raw, err := r.rawBody()
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return err
+		}
+		raw = wrapper[key]
+		if raw == nil {
+			return nil
+		}
+	}
+	err = json.Unmarshal(raw, v.Interface())
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+		return BindErrors{goFieldPath(v.Elem().Type(), typeErr.Field): fmt.Errorf("cannot use %s as %s", typeErr.Value, typeErr.Type)}
+	}
+	return err
+}
+
+// goFieldPath translates a dotted path of JSON names into one of Go field
+// names.
+func goFieldPath(t reflect.Type, jsonPath string) string {
+	var names []string
+	for _, segment := range strings.Split(jsonPath, ".") {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			names = append(names, segment)
+			continue
+		}
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if jsonName(f) == segment {
+				names = append(names, f.Name)
+				t = f.Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, segment)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func formName(f reflect.StructField) string {
+	if name := f.Tag.Get("form"); name != "" {
+		return name
+	}
+	return jsonName(f)
+}
+
+func formKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "[" + name + "]"
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func bindForm(values map[string][]string, prefix, path string, v reflect.Value, errs BindErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := formName(f)
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		key := formKey(prefix, name)
+		field := v.Field(i)
+		fieldPath := joinFieldPath(path, f.Name)
+		if isNestedStruct(f.Type) {
+			if field.Kind() == reflect.Ptr {
+				if !hasPrefixedKey(values, key+"[") {
+					continue
+				}
+				if field.IsNil() {
+					field.Set(reflect.New(f.Type.Elem()))
+				}
+				field = field.Elem()
+			}
+			bindForm(values, key, fieldPath, field, errs)
+			continue
+		}
+		raw, ok := values[key]
+		if !ok {
+			raw, ok = values[key+"[]"]
+		}
+		if !ok {
+			continue
+		}
+		if err := setFormValue(field, raw); err != nil {
+			errs[fieldPath] = err
+		}
+	}
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{}) && !reflect.PtrTo(t).Implements(textUnmarshaler)
+}
+
+func hasPrefixedKey(values map[string][]string, prefix string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setFormValue(v reflect.Value, raw []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(v.Type(), len(raw), len(raw))
+		for i, s := range raw {
+			if err := setScalar(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return setScalar(v, raw[0])
+}
+
+func setScalar(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setScalar(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "on" {
+			s = "true"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a non-negative integer", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("cannot bind to field of type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type BindSuite struct{}
+
+var _ = Suite(&BindSuite{})
+
+type bindAuthor struct {
+	Name  string `json:"name" form:"name"`
+	Email string `json:"email"`
+}
+
+type bindPost struct {
+	Title     string      `json:"title" xml:"title"`
+	Words     int         `json:"words" xml:"words"`
+	Draft     bool        `json:"draft"`
+	Tags      []string    `json:"tags" form:"tags"`
+	Published time.Time   `json:"published"`
+	Author    *bindAuthor `json:"author"`
+	Ignored   string      `form:"-"`
+}
+
+func bindRequest(method, url, contentType, body string) *Request {
+	raw, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if contentType != "" {
+		raw.Header.Set("Content-Type", contentType)
+	}
+	r := newRequest(raw)
+	r.parseBody(MaxBodySize)
+	return r
+}
+
+//Bind decodes JSON bodies with their struct tags
+func (s *BindSuite) TestBindJson(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/json", `{"title": "Hi", "words": 2, "author": {"name": "Jo"}}`)
+	post := &bindPost{}
+	c.Assert(r.Bind(post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+	c.Assert(post.Words, Equals, 2)
+	c.Assert(post.Author.Name, Equals, "Jo")
+	c.Assert(r.Params["title"], Equals, "Hi")
+}
+
+//BindParam decodes only the JSON value under the key
+func (s *BindSuite) TestBindParamJson(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/json", `{"post": {"title": "Hi"}}`)
+	post := &bindPost{}
+	c.Assert(r.BindParam("post", post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+}
+
+//JSON type mismatches are reported by Go field name
+func (s *BindSuite) TestBindJsonErrors(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/json", `{"author": {"name": 4}}`)
+	err := r.Bind(&bindPost{})
+	errs, ok := err.(BindErrors)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Author.Name"], NotNil)
+}
+
+//Bind decodes XML bodies
+func (s *BindSuite) TestBindXml(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/xml", `<post><title>Hi</title><words>2</words></post>`)
+	post := &bindPost{}
+	c.Assert(r.Bind(post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+	c.Assert(post.Words, Equals, 2)
+}
+
+//Bind matches form values to fields, including nested and repeated keys
+func (s *BindSuite) TestBindForm(c *C) {
+	body := "title=Hi&words=2&draft=on&tags=a&tags=b&published=2014-03-01T00:00:00Z&author[name]=Jo&Ignored=x"
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/x-www-form-urlencoded", body)
+	post := &bindPost{}
+	c.Assert(r.Bind(post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+	c.Assert(post.Words, Equals, 2)
+	c.Assert(post.Draft, Equals, true)
+	c.Assert(post.Tags, DeepEquals, []string{"a", "b"})
+	c.Assert(post.Published.Year(), Equals, 2014)
+	c.Assert(post.Author.Name, Equals, "Jo")
+	c.Assert(post.Ignored, Equals, "")
+}
+
+//BindParam reads bracketed form keys under the given key
+func (s *BindSuite) TestBindParamForm(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/x-www-form-urlencoded", "post[title]=Hi&post[tags][]=a&post[author][email]=jo@example.com")
+	post := &bindPost{}
+	c.Assert(r.BindParam("post", post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+	c.Assert(post.Tags, DeepEquals, []string{"a"})
+	c.Assert(post.Author.Email, Equals, "jo@example.com")
+}
+
+//Query parameters are bound for GET requests, and unset nested pointers stay nil
+func (s *BindSuite) TestBindQuery(c *C) {
+	r := bindRequest("GET", "http://127.0.0.1:8000/posts?title=Hi", "", "")
+	post := &bindPost{}
+	c.Assert(r.Bind(post), IsNil)
+	c.Assert(post.Title, Equals, "Hi")
+	c.Assert(post.Author, IsNil)
+}
+
+//Values that can't be converted are collected into BindErrors
+func (s *BindSuite) TestBindFormErrors(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/x-www-form-urlencoded", "title=Hi&words=many&draft=maybe")
+	post := &bindPost{}
+	err := r.Bind(post)
+	errs, ok := err.(BindErrors)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Words"], ErrorMatches, `"many" is not an integer`)
+	c.Assert(errs["Draft"], ErrorMatches, `"maybe" is not a boolean`)
+	c.Assert(post.Title, Equals, "Hi")
+}
+
+//Bind rejects destinations that aren't struct pointers and unknown content types
+func (s *BindSuite) TestBindInvalid(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "application/octet-stream", "...")
+	c.Assert(r.Bind(&bindPost{}), ErrorMatches, "Cannot bind request body of type application/octet-stream")
+	var s2 string
+	c.Assert(r.Bind(&s2), ErrorMatches, "Bind requires a pointer to a struct")
+}
@@ -0,0 +1,170 @@
+package gadget
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxBodySize is the largest request body, in bytes, that Gadget will read for
+// actions that haven't set their own limit with LimitBodySize.
+var MaxBodySize int64 = 10 << 20
+
+// ParseError describes a request body that Gadget could not parse. Status is
+// the HTTP status that the problem calls for: 400 for malformed bodies, 413 for
+// bodies over the size limit, and 415 for Content-Types that Gadget can't
+// parse.
+type ParseError struct {
+	Status int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", http.StatusText(e.Status), e.Err)
+}
+
+// RejectParseErrors is the default Filter for requests whose bodies could not
+// be parsed. It responds with the Status of the ParseError and an empty body.
+func RejectParseErrors(r *Request) (int, interface{}) {
+	if r.parseErr == nil {
+		return 0, nil
+	}
+	return r.parseErr.Status, ""
+}
+
+// IgnoreParseErrors lets actions run even when the request body could not be
+// parsed, leaving them to check Request.ParseError themselves.
+func IgnoreParseErrors(r *Request) (int, interface{}) { return 0, nil }
+
+// ParseError returns the error encountered while parsing the request body,
+// which is a *ParseError, or nil if there was none.
+func (r *Request) ParseError() error {
+	if r.parseErr == nil {
+		return nil
+	}
+	return r.parseErr
+}
+
+// parseBody populates Params (and Files, for multipart requests), reading at
+// most limit bytes of the request body. It only does any work the first time
+// it is called. Params is never nil afterwards, even if the body could not be
+// parsed.
+func (r *Request) parseBody(limit int64) {
+	if r.parsed {
+		return
+	}
+	r.parsed = true
+	r.Params = make(map[string]interface{})
+	if limit > 0 && !bodyless(r) {
+		if r.raw != nil && int64(len(r.raw)) > limit {
+			r.parseErr = &ParseError{413, fmt.Errorf("body of %d bytes exceeds limit of %d", len(r.raw), limit)}
+			return
+		}
+		if r.ContentLength > limit {
+			r.parseErr = &ParseError{413, fmt.Errorf("body of %d bytes exceeds limit of %d", r.ContentLength, limit)}
+			return
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, limit)
+	}
+	if err := r.readParams(r.Params); err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			r.parseErr = pe
+			return
+		}
+		status := 400
+		if errors.As(err, new(*http.MaxBytesError)) {
+			status = 413
+		}
+		r.parseErr = &ParseError{status, err}
+	}
+}
+
+// parseQuery populates Params from the query string alone, leaving the body
+// unread.
+func (r *Request) parseQuery() {
+	r.Params = make(map[string]interface{})
+	unpackValues(r.Params, r.URL.Query())
+}
+
+func (r *Request) readParams(params map[string]interface{}) error {
+	switch ct := r.contentType(); {
+	case bodyless(r):
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		r.Form = query
+		unpackValues(params, query)
+		return err
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		raw, err := r.readBody()
+		if err != nil {
+			return err
+		}
+		r.RawJson = raw
+		if len(raw) == 0 {
+			return nil
+		}
+		return json.Unmarshal(raw, &params)
+	case strings.HasPrefix(ct, "multipart/form-data"):
+		if err := r.ParseMultipartForm(MultipartMemory); err != nil {
+			return err
+		}
+		unpackValues(params, r.MultipartForm.Value)
+		r.Files = Files(r.MultipartForm.File)
+	case ct == "" || ct == "application/x-www-form-urlencoded" || isUnparsedType(ct):
+		if ct == "application/x-www-form-urlencoded" {
+			// Keep the raw body so that it can be verified by
+			// signature-based UserIdentifiers like ApiKeyAuth.
+			raw, err := r.readBody()
+			if err != nil {
+				return err
+			}
+			r.raw = raw
+			r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		}
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		unpackValues(params, r.Form)
+	default:
+		unpackValues(params, r.URL.Query())
+		return &ParseError{415, fmt.Errorf("cannot parse bodies of type %s", ct)}
+	}
+	return nil
+}
+
+// readBody returns the request body, or the copy kept by rawBody if a
+// UserIdentifier has already read it.
+func (r *Request) readBody() ([]byte, error) {
+	if r.raw != nil {
+		return r.raw, nil
+	}
+	raw, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	return raw, err
+}
+
+// UnparsedBodyTypes lists the Content-Types that Gadget accepts without
+// parsing them into Params, leaving the body for the action to read (with Bind,
+// for XML). Requests with bodies of any other type Gadget doesn't parse are
+// answered with a 415 unless the action ignores parse errors.
+var UnparsedBodyTypes = []string{"application/xml", "text/xml", "text/plain"}
+
+func isUnparsedType(ct string) bool {
+	if strings.HasSuffix(ct, "+xml") {
+		return true
+	}
+	for _, t := range UnparsedBodyTypes {
+		if t == ct {
+			return true
+		}
+	}
+	return false
+}
+
+func bodyless(r *Request) bool {
+	return r.Body == nil || r.Body == http.NoBody
+}
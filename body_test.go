@@ -0,0 +1,100 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type BodySuite struct{}
+
+type bodyApp struct {
+	*App
+}
+
+var ba *bodyApp
+
+var _ = Suite(&BodySuite{})
+
+func (s *BodySuite) SetUpSuite(c *C) {
+	ba = &bodyApp{&App{}}
+	ctlr := &HookController{}
+	ba.Register(ctlr)
+	ctlr.HandleParseErrors(IgnoreParseErrors, "update")
+	ctlr.LimitBodySize(8, "update")
+	ba.Accept("application/json").Via(JsonBroker)
+	ba.Routes(ba.Resource("hooks"))
+}
+
+func (s *BodySuite) TearDownSuite(c *C) {
+	ba.Controllers = make(map[string]Controller)
+}
+
+type HookController struct {
+	*DefaultController
+}
+
+func (c *HookController) Create(r *Request) (int, interface{}) {
+	return 201, r.Params["name"]
+}
+
+func (c *HookController) Update(r *Request) (int, interface{}) {
+	if err := r.ParseError(); err != nil {
+		return 202, err.(*ParseError).Status
+	}
+	return 200, len(r.Params)
+}
+
+func (s *BodySuite) send(method, path, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/"+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	ba.Handler()(resp, req)
+	return resp
+}
+
+//Well-formed bodies reach the action
+func (s *BodySuite) TestParsedBody(c *C) {
+	resp := s.send("POST", "hooks", "application/json", `{"name": "deploy"}`)
+	c.Assert(resp.Code, Equals, 201)
+	c.Assert(resp.Body.String(), Equals, `"deploy"`)
+}
+
+//Malformed bodies are refused with a 400
+func (s *BodySuite) TestMalformedBody(c *C) {
+	resp := s.send("POST", "hooks", "application/json", `{"name": `)
+	c.Assert(resp.Code, Equals, 400)
+	c.Assert(resp.Body.String(), Matches, `.*"status":400.*`)
+	c.Assert(s.send("POST", "hooks?name=%zz", "", "").Code, Equals, 400)
+}
+
+//Bodies of types Gadget can't parse are refused with a 415
+func (s *BodySuite) TestUnsupportedMediaType(c *C) {
+	c.Assert(s.send("POST", "hooks", "application/octet-stream", "\x00\x01").Code, Equals, 415)
+	c.Assert(s.send("POST", "hooks", "text/xml", "<hook/>").Code, Equals, 201)
+}
+
+//Actions that ignore parse errors can inspect them with ParseError
+func (s *BodySuite) TestIgnoredParseErrors(c *C) {
+	resp := s.send("PUT", "hooks/1", "application/json", `{"a":`)
+	c.Assert(resp.Code, Equals, 202)
+	c.Assert(resp.Body.String(), Equals, "400")
+	resp = s.send("PUT", "hooks/1", "application/json", `{"name": "much too long"}`)
+	c.Assert(resp.Body.String(), Equals, "413")
+	resp = s.send("PUT", "hooks/1", "application/octet-stream", "\x00")
+	c.Assert(resp.Body.String(), Equals, "415")
+	resp = s.send("PUT", "hooks/1", "application/json", `{}`)
+	c.Assert(resp.Code, Equals, 200)
+}
+
+//Params is never nil, even when the body can't be parsed
+func (s *BodySuite) TestParamsAfterParseError(c *C) {
+	raw, _ := http.NewRequest("POST", "http://127.0.0.1:8000/hooks?a=b", strings.NewReader("{"))
+	raw.Header.Set("Content-Type", "application/json")
+	r := newRequest(raw)
+	r.parseBody(MaxBodySize)
+	c.Assert(r.Params, NotNil)
+	c.Assert(r.ParseError(), ErrorMatches, "Bad Request: .*")
+}
@@ -0,0 +1,254 @@
+/*
+Package cache provides the storage backends for Gadget's response cache. Any
+type that satisfies Store can be passed to a Controller's Cache method; this
+package ships an in-memory LRU store and a file-backed store.
+*/
+package cache
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is the interface that cache backends implement. A ttl of zero means
+// the value never expires. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+	DeletePrefix(prefix string)
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && time.Now().After(expires)
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore is a Store that keeps up to a fixed number of values in memory,
+// evicting the least recently used value when it is full.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// NewMemoryStore returns a MemoryStore that holds at most capacity values.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for key if it has not expired.
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if expired(entry.expires) {
+		s.remove(el)
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set stores value for key, evicting the least recently used value if the
+// store is full.
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expiry(ttl)
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&memoryEntry{key, value, expiry(ttl)})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+}
+
+// Delete removes the value stored for key.
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+}
+
+// DeletePrefix removes every value whose key begins with prefix.
+func (s *MemoryStore) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, el := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(el)
+		}
+	}
+}
+
+// Len returns the number of values in the store.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
+}
+
+// fileHeader is written ahead of the value in each file, so that DeletePrefix
+// can match keys without reading the values.
+type fileHeader struct {
+	Key     string
+	Expires time.Time
+}
+
+// FileStore is a Store that keeps each value in its own file in a directory,
+// so that cached values survive restarts and can be shared by processes on
+// the same host.
+type FileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewFileStore returns a FileStore that writes to dir, creating it if
+// necessary.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%x", sha1.Sum([]byte(key))))
+}
+
+// readHeader decodes the header at the start of f, returning a decoder
+// positioned at the value.
+func readHeader(f *os.File) (*fileHeader, *gob.Decoder, error) {
+	dec := gob.NewDecoder(f)
+	header := &fileHeader{}
+	if err := dec.Decode(header); err != nil {
+		return nil, nil, err
+	}
+	return header, dec, nil
+}
+
+func (s *FileStore) read(key string) (*fileHeader, []byte, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	header, dec, err := readHeader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	var value []byte
+	if err := dec.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+	return header, value, nil
+}
+
+// Get returns the value stored for key if it has not expired.
+func (s *FileStore) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	header, value, err := s.read(key)
+	s.mu.RUnlock()
+	if err != nil || header.Key != key {
+		return nil, false
+	}
+	if expired(header.Expires) {
+		s.Delete(key)
+		return nil, false
+	}
+	return value, true
+}
+
+// Set writes value for key to the store's directory.
+func (s *FileStore) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := ioutil.TempFile(s.dir, ".tmp")
+	if err != nil {
+		return
+	}
+	enc := gob.NewEncoder(tmp)
+	err = enc.Encode(&fileHeader{key, expiry(ttl)})
+	if err == nil {
+		err = enc.Encode(value)
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	os.Rename(tmp.Name(), s.path(key))
+}
+
+// Delete removes the file for key.
+func (s *FileStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Remove(s.path(key))
+}
+
+// DeletePrefix removes the files for every key that begins with prefix. Only
+// the header of each file is decoded.
+func (s *FileStore) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(s.dir, info.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		header, _, err := readHeader(f)
+		f.Close()
+		if err == nil && strings.HasPrefix(header.Key, prefix) {
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StoreSuite struct {
+	stores map[string]Store
+	dir    string
+}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "gadget-cache")
+	c.Assert(err, IsNil)
+	s.dir = dir
+	fs, err := NewFileStore(dir)
+	c.Assert(err, IsNil)
+	s.stores = map[string]Store{
+		"memory": NewMemoryStore(10),
+		"file":   fs,
+	}
+}
+
+func (s *StoreSuite) TearDownTest(c *C) {
+	os.RemoveAll(s.dir)
+}
+
+//Stores return what was set until it is deleted
+func (s *StoreSuite) TestSetGetDelete(c *C) {
+	for name, store := range s.stores {
+		store.Set("posts/index", []byte("cached"), 0)
+		value, ok := store.Get("posts/index")
+		c.Assert(ok, Equals, true, Commentf(name))
+		c.Assert(string(value), Equals, "cached", Commentf(name))
+		store.Delete("posts/index")
+		_, ok = store.Get("posts/index")
+		c.Assert(ok, Equals, false, Commentf(name))
+	}
+}
+
+//Stores don't return expired values
+func (s *StoreSuite) TestTtl(c *C) {
+	for name, store := range s.stores {
+		store.Set("fleeting", []byte("here"), time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		_, ok := store.Get("fleeting")
+		c.Assert(ok, Equals, false, Commentf(name))
+	}
+}
+
+//DeletePrefix removes only the matching keys
+func (s *StoreSuite) TestDeletePrefix(c *C) {
+	for name, store := range s.stores {
+		store.Set("posts/index", []byte("1"), 0)
+		store.Set("posts/show", []byte("2"), 0)
+		store.Set("authors/index", []byte("3"), 0)
+		store.DeletePrefix("posts/")
+		_, ok := store.Get("posts/index")
+		c.Assert(ok, Equals, false, Commentf(name))
+		_, ok = store.Get("posts/show")
+		c.Assert(ok, Equals, false, Commentf(name))
+		_, ok = store.Get("authors/index")
+		c.Assert(ok, Equals, true, Commentf(name))
+	}
+}
+
+//MemoryStore evicts the least recently used value when full
+func (s *StoreSuite) TestMemoryStoreEviction(c *C) {
+	store := NewMemoryStore(2)
+	store.Set("a", []byte("a"), 0)
+	store.Set("b", []byte("b"), 0)
+	store.Get("a")
+	store.Set("c", []byte("c"), 0)
+	c.Assert(store.Len(), Equals, 2)
+	_, ok := store.Get("b")
+	c.Assert(ok, Equals, false)
+	_, ok = store.Get("a")
+	c.Assert(ok, Equals, true)
+}
+
+//FileStore.DeletePrefix matches keys without reading the values
+func (s *StoreSuite) TestFilestoreDeletePrefixReadsOnlyKeys(c *C) {
+	store := s.stores["file"].(*FileStore)
+	store.Set("posts/index", make([]byte, 10000), 0)
+	path := store.path("posts/index")
+	c.Assert(os.Truncate(path, 5000), IsNil)
+	store.DeletePrefix("posts/")
+	_, err := os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,148 @@
+package gadget
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/redneckbeard/gadget/cache"
+	"net/http"
+	"time"
+)
+
+// CachePolicy describes how the responses of cached actions are stored. See
+// DefaultController.Cache.
+type CachePolicy struct {
+	Store cache.Store
+	// TTL is how long a cached response is served. Zero means until the
+	// cache is expired explicitly or the Store evicts it.
+	TTL time.Duration
+	// UserKey, if set, returns a string identifying the user making the
+	// request, so that each user gets their own copy of the response.
+	UserKey func(*Request) string
+}
+
+type cachedResponse struct {
+	Status       int
+	Headers      http.Header
+	Final        string
+	LastModified time.Time
+}
+
+func (c *cachedResponse) response() *Response {
+	return &Response{
+		status:       c.Status,
+		Headers:      c.Headers,
+		final:        c.Final,
+		lastModified: c.LastModified,
+	}
+}
+
+func cachePrefix(controller, action string) string {
+	return controller + "/" + action + "\x00"
+}
+
+func (p *CachePolicy) key(r *Request, controller, action string) string {
+	key := cachePrefix(controller, action) + r.URL.Path + "?" + r.URL.Query().Encode() + "\x00" + r.ContentType() + "\x00" + r.Host
+	if p.UserKey != nil {
+		key += "\x00" + p.UserKey(r)
+	}
+	return key
+}
+
+// Cache stores the rendered responses of the named actions using policy.
+// Responses are keyed by host, path, query parameters, the MIME type the
+// client asked for and, if policy has a UserKey, the user. Only 200 responses
+// to GET and HEAD requests that don't set cookies or use the session, flashes
+// or CspNonce are cached, since those are particular to one client. Filters
+// still run on every request, so cached responses are never served to a client
+// that the Filters would have turned away.
+//
+// 	c := &PostController{}
+// 	gadget.Register(c)
+// 	c.Cache(&gadget.CachePolicy{
+// 		Store: cache.NewMemoryStore(1000),
+// 		TTL:   10 * time.Minute,
+// 	}, "index", "show")
+func (c *DefaultController) Cache(policy *CachePolicy, actions ...string) {
+	if c.filters == nil {
+		panic("Calls to Cache must be made after a controller is registered")
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to cache '%s' -- no such action", action))
+		}
+		c.caches[action] = policy
+	}
+}
+
+// ExpireCache removes every cached response for the named actions, or for
+// all cached actions if none are named. It is meant to be called from
+// methods like Create, Update, and Destroy.
+func (c *DefaultController) ExpireCache(actions ...string) {
+	if len(actions) == 0 {
+		for action := range c.caches {
+			actions = append(actions, action)
+		}
+	}
+	for _, action := range actions {
+		if policy, ok := c.caches[action]; ok {
+			policy.Store.DeletePrefix(cachePrefix(c.name, action))
+		}
+	}
+}
+
+// ExpireCachedPath removes the cached responses for a single URL path of the
+// named action, regardless of query parameters, MIME type or user.
+//
+// 	func (c *PostController) Update(r *gadget.Request) (int, interface{}) {
+// 		...
+// 		c.ExpireCache("index")
+// 		c.ExpireCachedPath("show", r.URL.Path)
+// 		return 200, post
+// 	}
+func (c *DefaultController) ExpireCachedPath(action, path string) {
+	if policy, ok := c.caches[action]; ok {
+		policy.Store.DeletePrefix(cachePrefix(c.name, action) + path + "?")
+	}
+}
+
+func (c *DefaultController) cached(r *Request, action string) *cachedResponse {
+	policy, ok := c.caches[action]
+	if !ok || (r.Method != "GET" && r.Method != "HEAD") {
+		return nil
+	}
+	raw, ok := policy.Store.Get(policy.key(r, c.name, action))
+	if !ok {
+		return nil
+	}
+	cached := &cachedResponse{}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(cached); err != nil {
+		return nil
+	}
+	return cached
+}
+
+func (c *DefaultController) storeCached(r *Request, action string, response *Response) {
+	policy, ok := c.caches[action]
+	if !ok || (r.Method != "GET" && r.Method != "HEAD") || response.status != 200 || len(response.Cookies) > 0 || r.personalized() {
+		return
+	}
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(&cachedResponse{
+		Status:       response.status,
+		Headers:      response.Headers,
+		Final:        response.final,
+		LastModified: response.lastModified,
+	})
+	if err == nil {
+		policy.Store.Set(policy.key(r, c.name, action), buf.Bytes(), policy.TTL)
+	}
+}
+
+// personalized reports whether the response to r may depend on, or will set
+// cookies for, state belonging to one client. The session and flash cookies are
+// only added when the response is written, after it has been cached, as is a
+// Content-Security-Policy that a cached CspNonce would no longer match.
+func (r *Request) personalized() bool {
+	return r.session != nil || r.flashIn != nil || len(r.flashOut) > 0 || r.nonce != ""
+}
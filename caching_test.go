@@ -0,0 +1,160 @@
+package gadget
+
+import (
+	"fmt"
+	"github.com/redneckbeard/gadget/cache"
+	"github.com/redneckbeard/gadget/session"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type CachingSuite struct{}
+
+type cachingApp struct {
+	*App
+}
+
+var cga *cachingApp
+
+var _ = Suite(&CachingSuite{})
+
+var renders int
+
+func (s *CachingSuite) SetUpTest(c *C) {
+	cga = &cachingApp{&App{}}
+	ctlr := &ReportController{}
+	cga.Register(ctlr)
+	ctlr.Cache(&CachePolicy{Store: cache.NewMemoryStore(100)}, "index", "show")
+	ctlr.Filter(func(r *Request) (int, interface{}) {
+		if r.Params["deny"] == "yes" {
+			return 403, "nope"
+		}
+		return 0, nil
+	}, "show")
+	cga.Accept("application/json").Via(JsonBroker)
+	cga.Routes(cga.Resource("reports"))
+	renders = 0
+}
+
+func (s *CachingSuite) TearDownTest(c *C) {
+	cga.Controllers = make(map[string]Controller)
+}
+
+type ReportController struct {
+	*DefaultController
+}
+
+func (c *ReportController) Index(r *Request) (int, interface{}) {
+	renders++
+	return 200, []int{renders}
+}
+
+func (c *ReportController) Show(r *Request) (int, interface{}) {
+	renders++
+	return 200, fmt.Sprintf("report %s, render %d", r.UrlParams["report_id"], renders)
+}
+
+func (c *ReportController) Form(r *Request) (int, interface{}) {
+	renders++
+	return 200, r.CsrfToken()
+}
+
+func (c *ReportController) Preview(r *Request) (int, interface{}) {
+	renders++
+	return 200, r.CspNonce()
+}
+
+func (c *ReportController) Create(r *Request) (int, interface{}) {
+	c.ExpireCache("index")
+	c.ExpireCachedPath("show", "/reports/1")
+	return 201, ""
+}
+
+func (s *CachingSuite) get(path, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/"+path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp := httptest.NewRecorder()
+	cga.Handler()(resp, req)
+	return resp
+}
+
+//Cached actions are only run once per path, query, and MIME type
+func (s *CachingSuite) TestResponsesAreCached(c *C) {
+	c.Assert(s.get("reports", "application/json").Body.String(), Equals, "[1]")
+	c.Assert(s.get("reports", "application/json").Body.String(), Equals, "[1]")
+	c.Assert(s.get("reports", "application/json").Header().Get("Content-Type"), Equals, "application/json")
+	c.Assert(s.get("reports?sort=asc", "application/json").Body.String(), Equals, "[2]")
+	c.Assert(s.get("reports", "text/plain").Body.String(), Equals, "[3]")
+	c.Assert(renders, Equals, 3)
+}
+
+//Filters run before the cache is consulted
+func (s *CachingSuite) TestFiltersRunOnCachedActions(c *C) {
+	c.Assert(s.get("reports/1", "").Code, Equals, 200)
+	c.Assert(s.get("reports/1?deny=yes", "").Code, Equals, 403)
+}
+
+//ExpireCache and ExpireCachedPath remove cached responses
+func (s *CachingSuite) TestExpiry(c *C) {
+	s.get("reports", "")
+	s.get("reports/1", "")
+	s.get("reports/2", "")
+	c.Assert(renders, Equals, 3)
+
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8000/reports", nil)
+	cga.Handler()(httptest.NewRecorder(), req)
+
+	s.get("reports", "")
+	s.get("reports/1", "")
+	s.get("reports/2", "")
+	c.Assert(renders, Equals, 5)
+}
+
+//UserKey gives each user a separate cached copy
+func (s *CachingSuite) TestPerUserCache(c *C) {
+	ctlr, _ := cga.getController("reports")
+	ctlr.Cache(&CachePolicy{
+		Store:   cache.NewMemoryStore(100),
+		UserKey: func(r *Request) string { return r.Header.Get("X-User") },
+	}, "index")
+	for _, user := range []string{"alice", "bob", "alice"} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/reports", nil)
+		req.Header.Set("X-User", user)
+		cga.Handler()(httptest.NewRecorder(), req)
+	}
+	c.Assert(renders, Equals, 2)
+}
+
+//Responses that use the session are not cached
+func (s *CachingSuite) TestResponsesUsingSessionNotCached(c *C) {
+	StoreSessionsWith(session.NewMemoryStore())
+	defer StoreSessionsWith(nil)
+	ctlr, _ := cga.getController("reports")
+	ctlr.Cache(&CachePolicy{Store: cache.NewMemoryStore(100)}, "form")
+	first, second := s.get("reports/form", ""), s.get("reports/form", "")
+	c.Assert(renders, Equals, 2)
+	c.Assert(second.Body.String(), Not(Equals), first.Body.String())
+	c.Assert(first.Header().Get("Set-Cookie"), Matches, SessionCookie.Name+"=.+")
+	c.Assert(second.Header().Get("Set-Cookie"), Matches, SessionCookie.Name+"=.+")
+}
+
+//Responses for the same path on different hosts are cached separately
+func (s *CachingSuite) TestHostsCachedSeparately(c *C) {
+	for _, host := range []string{"a.example.com", "b.example.com", "a.example.com"} {
+		req, _ := http.NewRequest("GET", "http://"+host+"/reports", nil)
+		cga.Handler()(httptest.NewRecorder(), req)
+	}
+	c.Assert(renders, Equals, 2)
+}
+
+//Responses that use the CSP nonce are not cached
+func (s *CachingSuite) TestResponsesUsingNonceNotCached(c *C) {
+	ctlr, _ := cga.getController("reports")
+	ctlr.Cache(&CachePolicy{Store: cache.NewMemoryStore(100)}, "preview")
+	first, second := s.get("reports/preview", ""), s.get("reports/preview", "")
+	c.Assert(renders, Equals, 2)
+	c.Assert(second.Body.String(), Not(Equals), first.Body.String())
+}
@@ -0,0 +1,185 @@
+/*
+Package compress negotiates gzip and deflate content encodings for HTTP
+responses. Gadget uses it for the responses rendered by its Brokers and for
+the static files served by the env package, so applications usually only need
+to adjust its settings.
+*/
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Enabled turns response compression on and off.
+	Enabled = true
+	// MinSize is the smallest response body, in bytes, worth compressing.
+	// Responses without a Content-Length are always candidates.
+	MinSize = 1024
+	// Level is the compression level passed to compress/gzip and
+	// compress/zlib.
+	Level = gzip.DefaultCompression
+	// Skip lists prefixes of MIME types whose content is already compressed.
+	Skip = []string{
+		"image/",
+		"video/",
+		"audio/",
+		"font/woff",
+		"application/zip",
+		"application/gzip",
+		"application/x-gzip",
+		"application/x-bzip2",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+		"application/pdf",
+		"application/octet-stream",
+	}
+)
+
+// Negotiate returns the content coding to use for a request with the given
+// Accept-Encoding header: "gzip", "deflate", or "" for none. gzip is
+// preferred when both are equally acceptable.
+func Negotiate(acceptEncoding string) string {
+	var (
+		best    string
+		bestQ   float64
+		star    = -1.0
+		refused = make(map[string]bool)
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch coding {
+		case "*":
+			star = q
+		case "gzip", "x-gzip", "deflate":
+			if coding == "x-gzip" {
+				coding = "gzip"
+			}
+			if q == 0 {
+				refused[coding] = true
+			} else if q > bestQ || (q == bestQ && coding == "gzip") {
+				best, bestQ = coding, q
+			}
+		}
+	}
+	if best == "" && star > 0 {
+		for _, coding := range []string{"gzip", "deflate"} {
+			if !refused[coding] {
+				return coding
+			}
+		}
+	}
+	return best
+}
+
+func skipped(contentType string) bool {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, s := range Skip {
+		if strings.HasPrefix(mime, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseWriter wraps an http.ResponseWriter and compresses what is written
+// to it when the request allows and the response qualifies. It decides when
+// the header is written, based on the status, Content-Type, Content-Length,
+// and Content-Encoding headers set at that point. Close must be called once
+// the body has been written.
+type ResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	head        bool
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+// NewResponseWriter returns a ResponseWriter for the response to r.
+func NewResponseWriter(w http.ResponseWriter, r *http.Request) *ResponseWriter {
+	return &ResponseWriter{
+		ResponseWriter: w,
+		encoding:       Negotiate(r.Header.Get("Accept-Encoding")),
+		head:           r.Method == "HEAD",
+	}
+}
+
+// WriteHeader sets the Content-Encoding and Vary headers as appropriate
+// before writing the status code.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.start(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseWriter) start(status int) {
+	h := w.Header()
+	if !Enabled || status < 200 || status == 204 || status == 206 || status == 304 {
+		return
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Type") == "" || skipped(h.Get("Content-Type")) {
+		return
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < MinSize {
+			return
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if w.encoding == "" || w.head {
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", w.encoding)
+	switch w.encoding {
+	case "gzip":
+		w.encoder, _ = gzip.NewWriterLevel(w.ResponseWriter, Level)
+	case "deflate":
+		w.encoder, _ = zlib.NewWriterLevel(w.ResponseWriter, Level)
+	}
+}
+
+// Write compresses b if the response qualified for compression.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(200)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Close flushes any compressed data to the underlying http.ResponseWriter.
+func (w *ResponseWriter) Close() error {
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// Handler wraps h so that its responses are compressed.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := NewResponseWriter(w, r)
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
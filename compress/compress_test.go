@@ -0,0 +1,99 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CompressSuite struct{}
+
+var _ = Suite(&CompressSuite{})
+
+var big = strings.Repeat("all work and no play makes jack a dull boy\n", 100)
+
+func serve(contentType, body, acceptEncoding string) *httptest.ResponseRecorder {
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(200)
+		w.Write([]byte(body))
+	}))
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/static/thing", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+//Negotiate honors q-values, prefers gzip, and understands the wildcard
+func (s *CompressSuite) TestNegotiate(c *C) {
+	cases := map[string]string{
+		"":                            "",
+		"identity":                    "",
+		"gzip, deflate, br":           "gzip",
+		"deflate":                     "deflate",
+		"gzip;q=0.5, deflate":         "deflate",
+		"gzip;q=0, deflate;q=0":       "",
+		"*":                           "gzip",
+		"gzip;q=0, *;q=0.1":           "deflate",
+		"x-gzip":                      "gzip",
+		"br;q=1.0, gzip;q=0.8, *;q=0": "gzip",
+	}
+	for header, expected := range cases {
+		c.Assert(Negotiate(header), Equals, expected, Commentf("Accept-Encoding: %s", header))
+	}
+}
+
+//Large responses are gzipped for clients that accept it
+func (s *CompressSuite) TestGzip(c *C) {
+	resp := serve("text/plain", big, "gzip")
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(resp.Header().Get("Content-Length"), Equals, "")
+	c.Assert(resp.Header().Get("Vary"), Equals, "Accept-Encoding")
+	reader, err := gzip.NewReader(resp.Body)
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, big)
+}
+
+//Large responses are deflated for clients that only accept deflate
+func (s *CompressSuite) TestDeflate(c *C) {
+	resp := serve("text/plain", big, "deflate")
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "deflate")
+	reader, err := zlib.NewReader(resp.Body)
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, big)
+}
+
+//Small responses and already-compressed types are left alone
+func (s *CompressSuite) TestSkipped(c *C) {
+	resp := serve("text/plain", "tiny", "gzip")
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "")
+	c.Assert(resp.Body.String(), Equals, "tiny")
+
+	resp = serve("image/png", big, "gzip")
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "")
+	c.Assert(resp.Header().Get("Vary"), Equals, "")
+}
+
+//Compressible responses vary on Accept-Encoding even when the client doesn't accept compression
+func (s *CompressSuite) TestVaryWithoutCompression(c *C) {
+	resp := serve("text/css", big, "")
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "")
+	c.Assert(resp.Header().Get("Vary"), Equals, "Accept-Encoding")
+	c.Assert(resp.Body.String(), Equals, big)
+}
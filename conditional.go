@@ -0,0 +1,91 @@
+package gadget
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// setETag computes a weak ETag from the final body of a successful response
+// to a safe request.
+func (r *Response) setETag(req *Request) {
+	if r.status != 200 || (req.Method != "GET" && req.Method != "HEAD") {
+		return
+	}
+	r.Headers.Set("ETag", fmt.Sprintf(`W/"%x"`, sha1.Sum([]byte(r.final))))
+}
+
+// SetLastModified sets the Last-Modified header of the Response. If the
+// request carries an If-Modified-Since header that is not before t (and no
+// If-None-Match header), Gadget will answer with a 304.
+func (r *Response) SetLastModified(t time.Time) {
+	r.lastModified = t.UTC().Truncate(time.Second)
+	r.Headers.Set("Last-Modified", r.lastModified.Format(http.TimeFormat))
+}
+
+// checkFreshness turns the response into a 304 Not Modified if the client's
+// cached copy is still current. If-None-Match takes precedence over
+// If-Modified-Since, as RFC 7232 requires.
+func (r *Response) checkFreshness(req *Request) {
+	if r.status != 200 || (req.Method != "GET" && req.Method != "HEAD") {
+		return
+	}
+	fresh := false
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := r.Headers.Get("ETag")
+		fresh = etag != "" && etagMatches(inm, etag)
+	} else if ims := req.Header.Get("If-Modified-Since"); ims != "" && !r.lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			fresh = !r.lastModified.After(t)
+		}
+	}
+	if fresh {
+		r.status = 304
+		r.final = ""
+		r.Headers.Del("Content-Type")
+	}
+}
+
+// etagMatches reports whether any entity tag in header matches etag. The
+// comparison is weak: W/ prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed evaluates an If-Match header on requests routed to
+// Update or Destroy. When the controller uses ETags for Show, the Show method
+// is called for the same resource and its ETag compared with the header, so
+// that clients can avoid overwriting changes they haven't seen. It runs after
+// the request has passed authorization and the action's Filters, and the Show
+// action's own Filters and cache are bypassed.
+func (a *App) preconditionFailed(rte *route, r *Request) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || !rte.controller.etagged("show") {
+		return false
+	}
+	if action := rte.GetActionName(r); action != "update" && action != "destroy" {
+		return false
+	}
+	raw := *r.Request
+	raw.Method = "GET"
+	show := *r
+	show.Request = &raw
+	status, body := rte.call(&show, "show")
+	if status < 200 || status >= 300 {
+		return true
+	}
+	if ifMatch == "*" {
+		return false
+	}
+	current := a.renderUncached(&show, rte, status, body, "show").Headers.Get("ETag")
+	return current == "" || !etagMatches(ifMatch, current)
+}
@@ -0,0 +1,157 @@
+package gadget
+
+import (
+	"github.com/redneckbeard/gadget/cache"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type ConditionalSuite struct{}
+
+type conditionalApp struct {
+	*App
+}
+
+var cda *conditionalApp
+
+var _ = Suite(&ConditionalSuite{})
+
+var (
+	articleBody     = "first draft"
+	articleModified = time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func (s *ConditionalSuite) SetUpTest(c *C) {
+	cda = &conditionalApp{&App{}}
+	ctlr := &ArticleController{}
+	cda.Register(ctlr)
+	ctlr.UseETags("index", "show")
+	cda.Routes(cda.Resource("articles"))
+	articleBody = "first draft"
+}
+
+func (s *ConditionalSuite) TearDownTest(c *C) {
+	cda.Controllers = make(map[string]Controller)
+}
+
+type ArticleController struct {
+	*DefaultController
+}
+
+func (c *ArticleController) Index(r *Request) (int, interface{}) {
+	response := NewResponse("all the articles")
+	response.SetLastModified(articleModified)
+	return 200, response
+}
+
+func (c *ArticleController) Show(r *Request) (int, interface{}) {
+	return 200, articleBody
+}
+
+func (c *ArticleController) Update(r *Request) (int, interface{}) {
+	articleBody = "second draft"
+	return 200, articleBody
+}
+
+func (s *ConditionalSuite) request(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/"+path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp := httptest.NewRecorder()
+	cda.Handler()(resp, req)
+	return resp
+}
+
+//Actions using ETags send a weak ETag and answer a matching If-None-Match with a 304
+func (s *ConditionalSuite) TestIfNoneMatch(c *C) {
+	resp := s.request("GET", "articles/1", nil)
+	etag := resp.Header().Get("ETag")
+	c.Assert(etag, Matches, `W/"[0-9a-f]{40}"`)
+
+	resp = s.request("GET", "articles/1", map[string]string{"If-None-Match": etag})
+	c.Assert(resp.Code, Equals, 304)
+	c.Assert(resp.Body.String(), Equals, "")
+
+	articleBody = "edited"
+	resp = s.request("GET", "articles/1", map[string]string{"If-None-Match": etag})
+	c.Assert(resp.Code, Equals, 200)
+}
+
+//Actions not using ETags don't get one
+func (s *ConditionalSuite) TestETagsAreOptIn(c *C) {
+	ctlr, _ := cda.getController("articles")
+	c.Assert(ctlr.etagged("update"), Equals, false)
+	c.Assert(func() { ctlr.UseETags("missing") }, PanicMatches, "Unable to use ETags for 'missing' -- no such action")
+}
+
+//SetLastModified sets the header and If-Modified-Since is answered with a 304 when the resource is unchanged
+func (s *ConditionalSuite) TestIfModifiedSince(c *C) {
+	resp := s.request("GET", "articles", nil)
+	c.Assert(resp.Header().Get("Last-Modified"), Equals, "Sat, 01 Mar 2014 12:00:00 GMT")
+
+	resp = s.request("GET", "articles", map[string]string{"If-Modified-Since": "Sat, 01 Mar 2014 12:00:00 GMT"})
+	c.Assert(resp.Code, Equals, 304)
+
+	resp = s.request("GET", "articles", map[string]string{"If-Modified-Since": "Fri, 28 Feb 2014 12:00:00 GMT"})
+	c.Assert(resp.Code, Equals, 200)
+}
+
+//Update requests with a stale If-Match are refused with a 412 and don't run the action
+func (s *ConditionalSuite) TestIfMatch(c *C) {
+	etag := s.request("GET", "articles/1", nil).Header().Get("ETag")
+
+	resp := s.request("PUT", "articles/1", map[string]string{"If-Match": `W/"stale"`})
+	c.Assert(resp.Code, Equals, 412)
+	c.Assert(articleBody, Equals, "first draft")
+
+	resp = s.request("PUT", "articles/1", map[string]string{"If-Match": etag})
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(articleBody, Equals, "second draft")
+}
+
+//If-Match should only be evaluated once the request has passed the Filters
+func (s *ConditionalSuite) TestIfMatchEvaluatedAfterFilters(c *C) {
+	ctlr, _ := cda.getController("articles")
+	ctlr.Filter(func(r *Request) (int, interface{}) {
+		if r.Header.Get("X-Auth") == "" {
+			return 401, ""
+		}
+		return 0, nil
+	}, "update")
+	stale := map[string]string{"If-Match": `W/"stale"`}
+	c.Assert(s.request("PUT", "articles/1", stale).Code, Equals, 401)
+	stale["X-Auth"] = "yes"
+	c.Assert(s.request("PUT", "articles/1", stale).Code, Equals, 412)
+	c.Assert(articleBody, Equals, "first draft")
+}
+
+//Evaluating If-Match should bypass Show's Filters and cache
+func (s *ConditionalSuite) TestIfMatchBypassesShowFiltersAndCache(c *C) {
+	ctlr, _ := cda.getController("articles")
+	var showFilterRuns int
+	ctlr.Filter(func(r *Request) (int, interface{}) {
+		showFilterRuns++
+		return 0, nil
+	}, "show")
+	ctlr.Cache(&CachePolicy{Store: cache.NewMemoryStore(10)}, "show")
+	c.Assert(s.request("PUT", "articles/1", map[string]string{"If-Match": `W/"stale"`}).Code, Equals, 412)
+	c.Assert(showFilterRuns, Equals, 0)
+	articleBody = "edited elsewhere"
+	c.Assert(s.request("GET", "articles/1", nil).Body.String(), Equals, "edited elsewhere")
+	c.Assert(showFilterRuns, Equals, 1)
+}
+
+//HEAD requests should be routed like GETs and get the same ETag and 304s
+func (s *ConditionalSuite) TestHeadRequestsGetEtagsAnd304s(c *C) {
+	etag := s.request("GET", "articles/1", nil).Header().Get("ETag")
+	resp := s.request("HEAD", "articles/1", nil)
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(resp.Header().Get("ETag"), Equals, etag)
+	resp = s.request("HEAD", "articles/1", map[string]string{"If-None-Match": etag})
+	c.Assert(resp.Code, Equals, 304)
+	resp = s.request("HEAD", "articles", map[string]string{"If-Modified-Since": articleModified.Format(http.TimeFormat)})
+	c.Assert(resp.Code, Equals, 304)
+}
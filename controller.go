@@ -40,10 +40,19 @@ var (
 // of verb, to that method.
 //
 // Controller also requires two methods that enable users to customize routing
-// options to this controller, IdPattern and Plural.  The final exported method of
-// the Controller interface is Filter, which allows for abstracting common
-// patterns from multiple Controller methods. All three of these methods are
-// documented in the fallback implementations provided by DefaultController.
+// options to this controller, IdPattern and Plural.  The remaining exported
+// methods of the Controller interface are Filter, which allows for abstracting
+// common patterns from multiple Controller methods; UseETags and Cache, which
+// opt actions in to conditional request handling and response caching
+// respectively; LimitBodySize and HandleParseErrors, which govern request
+// bodies; SkipCsrf; Authorize, Require, and RequireRole, which restrict
+// access to actions; Cors, which governs cross-origin requests; and
+// UseSecurityHeaders. All of these methods are documented in the fallback
+// implementations provided by DefaultController.
+//
+// OPTIONS requests for a controller's URLs are answered automatically with the
+// verbs routed to its actions and, for CORS preflight requests, with the
+// headers its CorsPolicy allows.
 //
 // Applications must inform Gadget of the existence of Controller types using
 // the Register function.
@@ -57,11 +66,30 @@ type Controller interface {
 	Filter(filter Filter, verbs ...string)
 	IdPattern() string
 	Plural() string
+	UseETags(actions ...string)
+	Cache(policy *CachePolicy, actions ...string)
+	LimitBodySize(size int64, actions ...string)
+	HandleParseErrors(filter Filter, actions ...string)
+	SkipCsrf(actions ...string)
+	Authorize(rule func(r *Request) bool, actions ...string)
+	Require(permission string, actions ...string)
+	RequireRole(role string, actions ...string)
+	Cors(policy *CorsPolicy)
+	UseSecurityHeaders(headers *SecurityHeaders)
 
+	bodyLimit(action string) int64
+	authorize(r *Request, action string) (int, interface{})
+	cached(r *Request, action string) *cachedResponse
+	corsPolicy() *CorsPolicy
+	csrfExempt(action string) bool
+	etagged(action string) bool
 	extraActionNames() []string
 	extraActions() map[string]string
+	parseErrorFilter(action string) Filter
 	runFilters(r *Request, action string) (int, interface{})
+	securityHeaders() *SecurityHeaders
 	setActions([][]string)
+	storeCached(r *Request, action string, response *Response)
 }
 
 func nameFromController(c Controller) string {
@@ -100,7 +128,7 @@ func (a *App) Register(clist ...Controller) {
 	for _, c := range clist {
 		v := reflect.ValueOf(c).Elem()
 		defaultCtlr := v.FieldByName("DefaultController")
-		defaultCtlr.Set(reflect.ValueOf(newController()))
+		defaultCtlr.Set(reflect.ValueOf(newController(pluralOf(c))))
 		c.setActions(arbitraryActions(c))
 		a.Controllers[pluralOf(c)] = c
 	}
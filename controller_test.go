@@ -71,3 +71,24 @@ func (s *ControllerSuite) TestControllerfiltermissingFilterShouldPanicController
 	ctlr, _ := ca.getController("tests")
 	c.Assert(func() { ctlr.Filter(F, "missing") }, PanicMatches, "Unable to add filter for 'missing' -- no such action")
 }
+
+//Every Filter should run until one of them returns a non-zero status
+func (s *ControllerSuite) TestEveryFilterRunsUntilOneReturnsNonzeroStatus(c *C) {
+	ctlr, _ := ca.getController("tests")
+	var ran []string
+	pass := func(r *Request) (int, interface{}) {
+		ran = append(ran, "pass")
+		return 0, nil
+	}
+	reject := func(r *Request) (int, interface{}) {
+		ran = append(ran, "reject")
+		return 403, "nope"
+	}
+	ctlr.Filter(pass, "index")
+	ctlr.Filter(reject, "index")
+	ctlr.Filter(pass, "index")
+	status, body := ctlr.runFilters(&Request{}, "index")
+	c.Assert(status, Equals, 403)
+	c.Assert(body, Equals, "nope")
+	c.Assert(ran, DeepEquals, []string{"pass", "reject"})
+}
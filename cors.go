@@ -0,0 +1,222 @@
+package gadget
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsPolicy describes which cross-origin requests browsers may make to an
+// application's controllers. Origins lists the origins allowed to make
+// requests, such as "https://app.example.com", or "*" for any origin. Methods
+// restricts the verbs allowed cross-origin; when it is empty, every verb that
+// the router maps to an action for the requested URL is allowed. Headers lists
+// the request headers that clients may send beyond the CORS-safelisted ones,
+// or "*" for any, and ExposeHeaders the response headers that scripts may
+// read. Credentials allows cookies and HTTP authentication to be sent, and
+// MaxAge is how long browsers may cache the response to a preflight request.
+// Credentials cannot be combined with "*" in Origins, since that would let any
+// site make requests with its visitors' cookies.
+type CorsPolicy struct {
+	Origins       []string
+	Methods       []string
+	Headers       []string
+	ExposeHeaders []string
+	Credentials   bool
+	MaxAge        time.Duration
+}
+
+// Cors sets the CorsPolicy for every controller in the App that hasn't set its
+// own with DefaultController.Cors. Without a policy, no cross-origin requests
+// are allowed. It panics if the policy allows credentials from any origin.
+//
+// 	app.Cors(&gadget.CorsPolicy{
+// 		Origins:     []string{"https://app.example.com"},
+// 		Headers:     []string{"Content-Type", "Authorization"},
+// 		Credentials: true,
+// 		MaxAge:      time.Hour,
+// 	})
+func (a *App) Cors(policy *CorsPolicy) {
+	policy.check()
+	a.cors = policy
+}
+
+// Cors sets the CorsPolicy for this controller's actions, overriding the one
+// set on the App. An empty CorsPolicy disallows cross-origin requests to the
+// controller. Like App.Cors, it panics if the policy allows credentials from
+// any origin.
+//
+// 	c := &WidgetController{}
+// 	gadget.Register(c)
+// 	c.Cors(&gadget.CorsPolicy{Origins: []string{"*"}, Methods: []string{"GET"}})
+func (c *DefaultController) Cors(policy *CorsPolicy) {
+	if c.filters == nil {
+		panic("Calls to Cors must be made after a controller is registered")
+	}
+	policy.check()
+	c.cors = policy
+}
+
+func (c *DefaultController) corsPolicy() *CorsPolicy {
+	return c.cors
+}
+
+func (a *App) corsPolicy(rte *route) *CorsPolicy {
+	if policy := rte.controller.corsPolicy(); policy != nil {
+		return policy
+	}
+	return a.cors
+}
+
+func (p *CorsPolicy) check() {
+	if p != nil && p.Credentials && contains(p.Origins, "*") {
+		panic("CorsPolicy cannot allow Credentials with an Origin of '*'")
+	}
+}
+
+func (p *CorsPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range p.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin sets the headers common to preflight and actual responses for a
+// request from origin.
+func (p *CorsPolicy) allowOrigin(headers http.Header, origin string) {
+	if contains(p.Origins, "*") {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.Credentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// methods returns the verbs allowed for the route, restricted to the policy's
+// Methods if it has any.
+func (p *CorsPolicy) methods(routed []string) []string {
+	if len(p.Methods) == 0 {
+		return routed
+	}
+	var allowed []string
+	for _, method := range routed {
+		if contains(p.Methods, method) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// allowsHeaders reports whether every header in the comma-separated list
+// requested is allowed.
+func (p *CorsPolicy) allowsHeaders(requested string) bool {
+	if contains(p.Headers, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !contains(p.Headers, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// methods returns the verbs that the router maps to an action for the
+// request's URL.
+func (rte *route) methods(r *Request) []string {
+	switch {
+	case rte.actionPattern != nil && rte.actionPattern.MatchString(r.Path):
+		return []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	case rte.indexPattern.MatchString(r.Path):
+		return []string{"GET", "HEAD", "POST"}
+	}
+	return []string{"GET", "HEAD", "PUT", "PATCH", "DELETE"}
+}
+
+// methodNotAllowed returns a 405 Response for a request whose URL is routed to
+// a controller but whose verb isn't, with the verbs that are in an Allow header.
+func (rte *route) methodNotAllowed(r *Request) *Response {
+	response := NewResponse("")
+	response.Headers.Set("Allow", strings.Join(append(rte.methods(r), "OPTIONS"), ", "))
+	return response
+}
+
+// options answers an OPTIONS request for a URL routed to a controller with the
+// verbs it accepts in an Allow header and, for CORS preflight requests that the
+// controller's CorsPolicy permits, the Access-Control headers that let the
+// browser go ahead with the actual request. It returns nil for URLs that aren't
+// routed to a controller.
+func (a *App) options(r *Request) *Response {
+	var rte *route
+	for _, candidate := range a.routes {
+		if candidate.Match(r) != nil {
+			rte = candidate
+			break
+		}
+	}
+	if rte == nil || rte.controller == nil {
+		return nil
+	}
+	r.security = rte.controller.securityHeaders()
+	response := NewResponse("")
+	response.status = 204
+	routed := rte.methods(r)
+	response.Headers.Set("Allow", strings.Join(append(routed, "OPTIONS"), ", "))
+	policy := a.corsPolicy(rte)
+	if policy != nil {
+		response.Headers.Add("Vary", "Origin")
+	}
+	origin := r.Request.Header.Get("Origin")
+	requested := r.Request.Header.Get("Access-Control-Request-Method")
+	if policy == nil || origin == "" || requested == "" || !policy.allowsOrigin(origin) {
+		return response
+	}
+	methods := policy.methods(routed)
+	requestedHeaders := r.Request.Header.Get("Access-Control-Request-Headers")
+	if !contains(methods, requested) || !policy.allowsHeaders(requestedHeaders) {
+		return response
+	}
+	policy.allowOrigin(response.Headers, origin)
+	response.Headers.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if requestedHeaders != "" {
+		response.Headers.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+	if policy.MaxAge > 0 {
+		response.Headers.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge/time.Second)))
+	}
+	return response
+}
+
+// allowCors adds CORS headers to the response to a cross-origin request routed
+// to rte if its CorsPolicy allows the origin. Responses to any request routed
+// to a controller with a policy vary by Origin, so that caches don't serve a
+// response meant for one origin to another.
+func (a *App) allowCors(rte *route, r *Request) {
+	policy := a.corsPolicy(rte)
+	if policy == nil {
+		return
+	}
+	headers := http.Header{"Vary": {"Origin"}}
+	if origin := r.Request.Header.Get("Origin"); origin != "" && policy.allowsOrigin(origin) {
+		policy.allowOrigin(headers, origin)
+		if len(policy.ExposeHeaders) > 0 {
+			headers.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+		}
+	}
+	r.addHeaders(headers)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
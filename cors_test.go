@@ -0,0 +1,160 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http/httptest"
+	"time"
+)
+
+type CorsSuite struct{}
+
+type corsApp struct {
+	*App
+}
+
+var cra *corsApp
+
+var _ = Suite(&CorsSuite{})
+
+func (s *CorsSuite) SetUpTest(c *C) {
+	cra = &corsApp{&App{}}
+	cra.Register(&TrackController{}, &AlbumController{})
+	cra.Cors(&CorsPolicy{
+		Origins:       []string{"https://app.example.com"},
+		Headers:       []string{"Content-Type", "X-Requested-With"},
+		ExposeHeaders: []string{"X-Total-Count"},
+		Credentials:   true,
+		MaxAge:        time.Hour,
+	})
+	cra.Controllers["albums"].Cors(&CorsPolicy{Origins: []string{"*"}, Methods: []string{"GET"}})
+	cra.Routes(cra.Resource("tracks"), cra.Resource("albums"))
+}
+
+func (s *CorsSuite) TearDownTest(c *C) {
+	cra.Controllers = make(map[string]Controller)
+}
+
+type TrackController struct {
+	*DefaultController
+}
+
+func (c *TrackController) Index(r *Request) (int, interface{}) {
+	return 200, "tracks"
+}
+
+func (c *TrackController) Play(r *Request) (int, interface{}) {
+	return 200, "playing"
+}
+
+type AlbumController struct {
+	*DefaultController
+}
+
+func (c *AlbumController) Index(r *Request) (int, interface{}) {
+	return 200, "albums"
+}
+
+func (s *CorsSuite) send(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://127.0.0.1:8000/"+path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	cra.Handler()(resp, req)
+	return resp
+}
+
+func preflight(origin, method, headers string) map[string]string {
+	return map[string]string{
+		"Origin":                         origin,
+		"Access-Control-Request-Method":  method,
+		"Access-Control-Request-Headers": headers,
+	}
+}
+
+//OPTIONS requests should be answered with the verbs routed to actions
+func (s *CorsSuite) TestOptionsRequestsAnsweredWithRoutedVerbs(c *C) {
+	resp := s.send("OPTIONS", "tracks", nil)
+	c.Assert(resp.Code, Equals, 204)
+	c.Assert(resp.Header().Get("Allow"), Equals, "GET, HEAD, POST, OPTIONS")
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+	resp = s.send("OPTIONS", "tracks/1", nil)
+	c.Assert(resp.Header().Get("Allow"), Equals, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS")
+	resp = s.send("OPTIONS", "tracks/play", nil)
+	c.Assert(resp.Header().Get("Allow"), Equals, "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+}
+
+//OPTIONS requests for URLs that aren't routed should get a 404
+func (s *CorsSuite) TestOptionsRequestsForUnroutedUrlsGet404(c *C) {
+	resp := s.send("OPTIONS", "nothing-here", nil)
+	c.Assert(resp.Code, Equals, 404)
+}
+
+//A preflight request from an allowed origin should get the Access-Control headers
+func (s *CorsSuite) TestPreflightFromAllowedOriginGetsAccessControlHeaders(c *C) {
+	resp := s.send("OPTIONS", "tracks", preflight("https://app.example.com", "POST", "content-type"))
+	c.Assert(resp.Code, Equals, 204)
+	h := resp.Header()
+	c.Assert(h.Get("Access-Control-Allow-Origin"), Equals, "https://app.example.com")
+	c.Assert(h.Get("Access-Control-Allow-Methods"), Equals, "GET, HEAD, POST")
+	c.Assert(h.Get("Access-Control-Allow-Headers"), Equals, "content-type")
+	c.Assert(h.Get("Access-Control-Allow-Credentials"), Equals, "true")
+	c.Assert(h.Get("Access-Control-Max-Age"), Equals, "3600")
+	c.Assert(h.Get("Vary"), Matches, ".*Origin.*")
+}
+
+//A preflight request from another origin should get no Access-Control headers
+func (s *CorsSuite) TestPreflightFromOtherOriginGetsNoAccessControlHeaders(c *C) {
+	resp := s.send("OPTIONS", "tracks", preflight("https://evil.example.com", "POST", ""))
+	c.Assert(resp.Code, Equals, 204)
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+}
+
+//A preflight request for a header that isn't allowed should get no Access-Control headers
+func (s *CorsSuite) TestPreflightForDisallowedHeaderGetsNoAccessControlHeaders(c *C) {
+	resp := s.send("OPTIONS", "tracks", preflight("https://app.example.com", "POST", "X-Secret"))
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+}
+
+//A cross-origin request from an allowed origin should get the Access-Control headers
+func (s *CorsSuite) TestCrossOriginRequestFromAllowedOriginGetsAccessControlHeaders(c *C) {
+	resp := s.send("GET", "tracks", map[string]string{"Origin": "https://app.example.com"})
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "https://app.example.com")
+	c.Assert(resp.Header().Get("Access-Control-Expose-Headers"), Equals, "X-Total-Count")
+	resp = s.send("GET", "tracks", nil)
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+}
+
+//A controller's CorsPolicy should override the App's
+func (s *CorsSuite) TestControllersCorspolicyOverridesApps(c *C) {
+	resp := s.send("GET", "albums", map[string]string{"Origin": "https://elsewhere.example.com"})
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "*")
+	resp = s.send("OPTIONS", "albums", preflight("https://elsewhere.example.com", "GET", ""))
+	c.Assert(resp.Header().Get("Access-Control-Allow-Methods"), Equals, "GET")
+	resp = s.send("OPTIONS", "albums", preflight("https://elsewhere.example.com", "POST", ""))
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+}
+
+//Calling Cors before a controller is registered should panic
+func (s *CorsSuite) TestCallingCorsBeforeRegisteringPanics(c *C) {
+	ctlr := &AlbumController{&DefaultController{}}
+	c.Assert(func() { ctlr.Cors(&CorsPolicy{}) }, PanicMatches, "Calls to Cors must be made after a controller is registered")
+}
+
+//A CorsPolicy allowing credentials from any origin should panic
+func (s *CorsSuite) TestCorspolicyAllowingCredentialsFromAnyOriginPanics(c *C) {
+	policy := &CorsPolicy{Origins: []string{"*"}, Credentials: true}
+	c.Assert(func() { cra.Cors(policy) }, PanicMatches, "CorsPolicy cannot allow Credentials with an Origin of '\\*'")
+	c.Assert(func() { cra.Controllers["albums"].Cors(policy) }, PanicMatches, "CorsPolicy cannot allow Credentials with an Origin of '\\*'")
+}
+
+//Responses from controllers with a CorsPolicy should always vary by Origin
+func (s *CorsSuite) TestResponsesFromControllersWithCorspolicyVaryByOrigin(c *C) {
+	c.Assert(s.send("GET", "tracks", nil).Header().Get("Vary"), Equals, "Origin")
+	c.Assert(s.send("GET", "tracks", map[string]string{"Origin": "https://evil.example.com"}).Header().Get("Vary"), Equals, "Origin")
+	c.Assert(s.send("GET", "albums", map[string]string{"Origin": "https://elsewhere.example.com"}).Header().Get("Vary"), Equals, "Origin")
+	c.Assert(s.send("OPTIONS", "tracks", nil).Header().Get("Vary"), Equals, "Origin")
+	cra.Cors(nil)
+	c.Assert(s.send("GET", "tracks", nil).Header().Get("Vary"), Equals, "")
+}
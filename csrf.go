@@ -0,0 +1,94 @@
+package gadget
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/redneckbeard/gadget/env"
+)
+
+var (
+	// CsrfProtection turns on verification of CSRF tokens for POST, PUT,
+	// PATCH, and DELETE requests to every controller action that hasn't been
+	// exempted with SkipCsrf. It requires sessions (see StoreSessionsWith),
+	// as each session has its own token; without them, those requests are
+	// refused.
+	CsrfProtection = false
+	// CsrfHeader is the request header checked for the token, for
+	// JavaScript clients.
+	CsrfHeader = "X-CSRF-Token"
+	// CsrfField is the form field checked for the token when the header is
+	// absent.
+	CsrfField = "csrf_token"
+)
+
+const csrfKey = "_csrf_secret"
+
+var csrfEncoding = base64.RawURLEncoding
+
+// CsrfToken returns a token to include in forms (as the CsrfField field) or
+// in the CsrfHeader header of requests that change state. The templates
+// package exposes it as the "csrf_token" helper:
+//
+// 	<input type="hidden" name="csrf_token" value="{{csrf_token}}">
+//
+// Each call returns a differently masked copy of the session's secret, so that
+// the token can't be recovered from compressed responses.
+func (r *Request) CsrfToken() string {
+	secret := r.csrfSecret()
+	pad := make([]byte, len(secret))
+	rand.Read(pad)
+	masked := make([]byte, len(secret))
+	for i := range secret {
+		masked[i] = pad[i] ^ secret[i]
+	}
+	return csrfEncoding.EncodeToString(append(pad, masked...))
+}
+
+func (r *Request) csrfSecret() []byte {
+	sess := r.Session()
+	if encoded, ok := sess.Get(csrfKey).(string); ok {
+		if secret, err := csrfEncoding.DecodeString(encoded); err == nil {
+			return secret
+		}
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	sess.Set(csrfKey, csrfEncoding.EncodeToString(secret))
+	return secret
+}
+
+// verifyCsrf refuses unsafe requests that don't carry the session's CSRF
+// token with a 403. Without a session store there is no token to check, so
+// every unsafe request is refused.
+func verifyCsrf(r *Request) (int, interface{}) {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return 0, nil
+	}
+	if sessionStore == nil {
+		env.Log("CsrfProtection requires sessions; call StoreSessionsWith to configure a session store")
+		return 403, ""
+	}
+	token := r.Header.Get(CsrfHeader)
+	if token == "" {
+		token, _ = r.Params[CsrfField].(string)
+	}
+	if !validCsrfToken(r.csrfSecret(), token) {
+		return 403, ""
+	}
+	return 0, nil
+}
+
+func validCsrfToken(secret []byte, token string) bool {
+	raw, err := csrfEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*len(secret) {
+		return false
+	}
+	pad, masked := raw[:len(secret)], raw[len(secret):]
+	unmasked := make([]byte, len(secret))
+	for i := range secret {
+		unmasked[i] = pad[i] ^ masked[i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
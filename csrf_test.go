@@ -0,0 +1,133 @@
+package gadget
+
+import (
+	"github.com/redneckbeard/gadget/session"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+type CsrfSuite struct{}
+
+type csrfApp struct {
+	*App
+}
+
+var xa *csrfApp
+
+var _ = Suite(&CsrfSuite{})
+
+func (s *CsrfSuite) SetUpTest(c *C) {
+	xa = &csrfApp{&App{}}
+	xa.Register(&LedgerController{}, &PingController{})
+	ping, _ := xa.getController("pings")
+	ping.SkipCsrf()
+	xa.Accept("application/json").Via(JsonBroker)
+	xa.Routes(xa.Resource("ledgers"), xa.Resource("pings"))
+	StoreSessionsWith(session.NewMemoryStore())
+	CsrfProtection = true
+}
+
+func (s *CsrfSuite) TearDownTest(c *C) {
+	xa.Controllers = make(map[string]Controller)
+	StoreSessionsWith(nil)
+	CsrfProtection = false
+}
+
+type LedgerController struct {
+	*DefaultController
+}
+
+func (c *LedgerController) Index(r *Request) (int, interface{}) {
+	return 200, r.CsrfToken()
+}
+
+func (c *LedgerController) Create(r *Request) (int, interface{}) {
+	return 201, "created"
+}
+
+type PingController struct {
+	*DefaultController
+}
+
+func (c *PingController) Create(r *Request) (int, interface{}) {
+	return 201, "pong"
+}
+
+func (s *CsrfSuite) do(req *http.Request, cookie *http.Cookie) *httptest.ResponseRecorder {
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	xa.Handler()(resp, req)
+	return resp
+}
+
+// token fetches a token and the session cookie it belongs to.
+func (s *CsrfSuite) token(c *C) (string, *http.Cookie) {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/ledgers", nil)
+	resp := s.do(req, nil)
+	cookie := sessionCookie(resp)
+	c.Assert(cookie, NotNil)
+	return resp.Body.String(), cookie
+}
+
+func formPost(path, token string) *http.Request {
+	form := url.Values{"amount": {"10"}}
+	if token != "" {
+		form.Set(CsrfField, token)
+	}
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8000/"+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+//Unsafe requests without a token are refused with a 403
+func (s *CsrfSuite) TestMissingToken(c *C) {
+	_, cookie := s.token(c)
+	c.Assert(s.do(formPost("ledgers", ""), cookie).Code, Equals, 403)
+	req := formPost("ledgers", "")
+	req.Header.Set("Accept", "application/json")
+	resp := s.do(req, nil)
+	c.Assert(resp.Code, Equals, 403)
+	c.Assert(resp.Body.String(), Matches, `.*"status":403.*`)
+}
+
+//Tokens are accepted from the form field or the header
+func (s *CsrfSuite) TestValidToken(c *C) {
+	token, cookie := s.token(c)
+	c.Assert(s.do(formPost("ledgers", token), cookie).Code, Equals, 201)
+	req := formPost("ledgers", "")
+	req.Header.Set(CsrfHeader, token)
+	c.Assert(s.do(req, cookie).Code, Equals, 201)
+}
+
+//Every token is masked differently but all are valid for the session
+func (s *CsrfSuite) TestMaskedTokens(c *C) {
+	token, cookie := s.token(c)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/ledgers", nil)
+	other := s.do(req, cookie).Body.String()
+	c.Assert(other, Not(Equals), token)
+	c.Assert(s.do(formPost("ledgers", other), cookie).Code, Equals, 201)
+}
+
+//Tokens from another session are refused
+func (s *CsrfSuite) TestForeignToken(c *C) {
+	token, _ := s.token(c)
+	_, cookie := s.token(c)
+	c.Assert(s.do(formPost("ledgers", token), cookie).Code, Equals, 403)
+}
+
+//Controllers exempted with SkipCsrf are not checked
+func (s *CsrfSuite) TestSkipCsrf(c *C) {
+	c.Assert(s.do(formPost("pings", ""), nil).Code, Equals, 201)
+}
+
+//Unsafe requests are refused when CsrfProtection is on but sessions are not configured
+func (s *CsrfSuite) TestNoSessionStore(c *C) {
+	StoreSessionsWith(nil)
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8000/ledgers", nil)
+	c.Assert(s.do(req, nil).Code, Equals, 403)
+}
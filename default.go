@@ -2,10 +2,16 @@ package gadget
 
 import "fmt"
 
-func newController() *DefaultController {
-	controller := &DefaultController{}
+func newController(name string) *DefaultController {
+	controller := &DefaultController{name: name}
 	controller.filters = make(map[string][]Filter)
 	controller.extraActionMap = make(map[string]string)
+	controller.etags = make(map[string]bool)
+	controller.caches = make(map[string]*CachePolicy)
+	controller.bodyLimits = make(map[string]int64)
+	controller.parseErrorFilters = make(map[string]Filter)
+	controller.csrfExemptions = make(map[string]bool)
+	controller.rules = make(map[string][]func(*Request) bool)
 	return controller
 }
 
@@ -20,8 +26,17 @@ func newController() *DefaultController {
 // 	* The return value of Plural is "", which Register takes to mean "just
 // 	  add an 's'"
 type DefaultController struct {
-	filters        map[string][]Filter
-	extraActionMap map[string]string
+	name              string
+	filters           map[string][]Filter
+	extraActionMap    map[string]string
+	etags             map[string]bool
+	caches            map[string]*CachePolicy
+	bodyLimits        map[string]int64
+	parseErrorFilters map[string]Filter
+	csrfExemptions    map[string]bool
+	rules             map[string][]func(*Request) bool
+	cors              *CorsPolicy
+	security          *SecurityHeaders
 }
 
 // Filter is simply a function with the same signature as a controller method
@@ -65,7 +80,7 @@ func (c *DefaultController) Plural() string { return "" }
 // 	c := &PostController{}
 // 	gadget.Register(c)
 // 	c.Filter(func(r *gadget.Request) (int, interface{}) {
-// 		if !r.User.Authenticated() {
+// 		if !r.User().Authenticated() {
 // 			return 403, "Verboten"
 // 		}
 // 	}, "create", "update", "destroy")
@@ -82,10 +97,129 @@ func (c *DefaultController) Filter(filter Filter, verbs ...string) {
 	}
 }
 
+// UseETags turns on conditional request handling for the named actions. Gadget
+// will compute a weak ETag from the body of every 200 response to a GET or
+// HEAD request for those actions and answer a matching If-None-Match header
+// with a 304 Not Modified.
+//
+// When "show" is among the actions, Update and Destroy requests that carry an
+// If-Match header are checked against the current ETag of the resource as
+// Show would render it, and are refused with a 412 Precondition Failed if it
+// has changed.
+//
+// 	c := &PostController{}
+// 	gadget.Register(c)
+// 	c.UseETags("index", "show")
+func (c *DefaultController) UseETags(actions ...string) {
+	if c.filters == nil {
+		panic("Calls to UseETags must be made after a controller is registered")
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to use ETags for '%s' -- no such action", action))
+		}
+		c.etags[action] = true
+	}
+}
+
+func (c *DefaultController) etagged(action string) bool {
+	return c.etags[action]
+}
+
+// LimitBodySize sets the largest request body, in bytes, that the named
+// actions will accept, overriding MaxBodySize. Requests with larger bodies are
+// answered with a 413 Request Entity Too Large before any Filters run, unless
+// the action handles parse errors differently (see HandleParseErrors).
+//
+// 	c := &PhotoController{}
+// 	gadget.Register(c)
+// 	c.LimitBodySize(50<<20, "create", "update")
+func (c *DefaultController) LimitBodySize(size int64, actions ...string) {
+	if c.filters == nil {
+		panic("Calls to LimitBodySize must be made after a controller is registered")
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to limit body size for '%s' -- no such action", action))
+		}
+		c.bodyLimits[action] = size
+	}
+}
+
+func (c *DefaultController) bodyLimit(action string) int64 {
+	if size, ok := c.bodyLimits[action]; ok {
+		return size
+	}
+	return MaxBodySize
+}
+
+// HandleParseErrors sets the Filter that decides how the named actions, or all
+// of the controller's actions if none are named, respond when the request body
+// could not be parsed. It runs before any other Filters. The default,
+// RejectParseErrors, answers with a 400, 413, or 415 as appropriate;
+// IgnoreParseErrors lets the action run and inspect Request.ParseError itself.
+//
+// 	c := &WebhookController{}
+// 	gadget.Register(c)
+// 	c.HandleParseErrors(gadget.IgnoreParseErrors, "create")
+func (c *DefaultController) HandleParseErrors(filter Filter, actions ...string) {
+	if c.filters == nil {
+		panic("Calls to HandleParseErrors must be made after a controller is registered")
+	}
+	if len(actions) == 0 {
+		for action := range c.filters {
+			actions = append(actions, action)
+		}
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to handle parse errors for '%s' -- no such action", action))
+		}
+		c.parseErrorFilters[action] = filter
+	}
+}
+
+func (c *DefaultController) parseErrorFilter(action string) Filter {
+	if filter, ok := c.parseErrorFilters[action]; ok {
+		return filter
+	}
+	return RejectParseErrors
+}
+
+// SkipCsrf exempts the named actions, or all of the controller's actions if
+// none are named, from CSRF token verification. It is meant for controllers
+// that serve JSON APIs to clients that authenticate without cookies.
+//
+// 	c := &ApiController{}
+// 	gadget.Register(c)
+// 	c.SkipCsrf()
+func (c *DefaultController) SkipCsrf(actions ...string) {
+	if c.filters == nil {
+		panic("Calls to SkipCsrf must be made after a controller is registered")
+	}
+	if len(actions) == 0 {
+		for action := range c.filters {
+			actions = append(actions, action)
+		}
+	}
+	for _, action := range actions {
+		if _, ok := c.filters[action]; !ok {
+			panic(fmt.Sprintf("Unable to skip CSRF verification for '%s' -- no such action", action))
+		}
+		c.csrfExemptions[action] = true
+	}
+}
+
+func (c *DefaultController) csrfExempt(action string) bool {
+	return c.csrfExemptions[action]
+}
+
+// runFilters runs the action's Filters in the order they were added, stopping
+// at the first one that returns a non-zero status.
 func (c *DefaultController) runFilters(r *Request, action string) (status int, body interface{}) {
 	for _, f := range c.filters[action] {
 		status, body = f(r)
-		if status == 0 {
+		if status != 0 {
 			return
 		}
 	}
@@ -9,12 +9,19 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/redneckbeard/gadget/compress"
 	"github.com/redneckbeard/quimby"
 )
 
 var (
 	root, staticPrefix, logFilePath, port string
+	certFile, keyFile                     string
+	socketPath, socketMode                string
+	readTimeout, writeTimeout             time.Duration
+	idleTimeout, drainTimeout             time.Duration
+	restartTimeout                        time.Duration
 	logger                                *log.Logger
 	messages                              = make(chan []interface{})
 	Booting                               = make(chan bool)
@@ -48,9 +55,31 @@ func (s *Serve) SetFlags() {
 	s.StringVar(&root, "root", "", "Directory that contains uncompiled application assets. Defaults to current working directory.")
 	s.StringVar(&logFilePath, "log", "", "Path to log file")
 	s.StringVar(&port, "port", "8090", "port on which the application will listen")
+	s.StringVar(&socketPath, "socket", "", "Path to a unix domain socket to listen on instead of a port")
+	s.StringVar(&socketMode, "socket-mode", "0660", "Permissions for the unix domain socket, in octal")
+	s.StringVar(&certFile, "tls-cert", "", "Path to a TLS certificate; serves HTTPS when given along with -tls-key")
+	s.StringVar(&keyFile, "tls-key", "", "Path to the private key for the TLS certificate")
+	s.DurationVar(&readTimeout, "read-timeout", 30*time.Second, "Maximum duration for reading an entire request, including the body")
+	s.DurationVar(&writeTimeout, "write-timeout", 60*time.Second, "Maximum duration before timing out writes of a response")
+	s.DurationVar(&idleTimeout, "idle-timeout", 120*time.Second, "Maximum duration to wait for the next request on a keep-alive connection")
+	s.DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "Maximum duration to wait for in-flight requests when shutting down")
+	s.DurationVar(&restartTimeout, "restart-timeout", 30*time.Second, "Maximum duration to wait for a new process to become ready when restarting")
 }
 
-// Run sets up a logger and runs the Handler.
+// Run sets up a logger and runs the Handler until the process receives SIGTERM
+// or SIGINT. It then stops accepting connections, waits for in-flight requests
+// to finish, and runs the hooks registered with OnShutdown.
+//
+// The Handler listens on a socket passed by systemd socket activation if there
+// is one, on the unix domain socket given with -socket, or on -port otherwise.
+//
+// On SIGHUP or SIGUSR2, Run restarts without dropping connections: it starts a
+// new process with the same arguments, hands it the listening socket, and once
+// the new process is ready, shuts down as it would on SIGTERM. If the new
+// process fails to start or isn't ready within -restart-timeout, the old one
+// keeps serving. Process supervisors that track the main PID, like systemd,
+// must be told about the new process, or they will stop the service when the
+// old one exits.
 func (s *Serve) Run() {
 	if root == "" {
 		if wd, err := os.Getwd(); err != nil {
@@ -80,14 +109,19 @@ func (s *Serve) Run() {
 			logger.Println(msg...)
 		}
 	}()
+	if err := checkTls(); err != nil {
+		panic(err)
+	}
 	close(Booting)
 	serveStatic()
 	http.HandleFunc("/", Handler)
-	Log("Running Gadget at 0.0.0.0:" + port + "...")
-	err := http.ListenAndServe(":"+port, nil)
+	listener, err := listen()
 	if err != nil {
 		panic(err)
 	}
+	if err := serve(newServer(), listener); err != nil {
+		panic(err)
+	}
 }
 
 // RelPath creates an absolute path from path segments path relative to the project root.
@@ -96,7 +130,7 @@ func RelPath(path ...string) string {
 }
 
 func serveStatic() {
-	http.Handle(staticPrefix, http.StripPrefix(staticPrefix, http.FileServer(http.Dir(RelPath("static")))))
+	http.Handle(staticPrefix, compress.Handler(http.StripPrefix(staticPrefix, http.FileServer(http.Dir(RelPath("static"))))))
 }
 
 // Open wraps os.Open, but with the assumption that the path is relative to the project root.
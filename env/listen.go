@@ -0,0 +1,95 @@
+package env
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation; 0, 1 and 2 are stdin, stdout and stderr.
+const listenFdsStart = 3
+
+// listen returns the listener that the serve command accepts connections on.
+// A socket handed down by a restarting serve process or passed by systemd
+// takes precedence over the -socket flag, which in turn takes precedence over
+// -port.
+func listen() (net.Listener, error) {
+	if l, err := inheritedListener(); l != nil || err != nil {
+		return l, err
+	}
+	if l, err := systemdListener(); l != nil || err != nil {
+		return l, err
+	}
+	if socketPath != "" {
+		return unixListener(socketPath, socketMode)
+	}
+	return net.Listen("tcp", ":"+port)
+}
+
+// systemdListener returns the first socket passed to the process by systemd
+// socket activation, or nil if there is none. It follows sd_listen_fds(3):
+// LISTEN_PID must name this process, and the variables are unset so that child
+// processes don't mistake the sockets for their own.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if n > 1 {
+		Log(fmt.Sprintf("systemd passed %d sockets; listening on the first", n))
+	}
+	f := os.NewFile(uintptr(listenFdsStart), "LISTEN_FD_3")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to use socket passed by systemd: %s", err)
+	}
+	return l, nil
+}
+
+// unixListener listens on a unix domain socket at path with the permissions in
+// mode, an octal string. The umask is narrowed while the socket is created, so
+// that it is never more open than mode allows. A socket file left behind by a
+// previous run is removed first, but any other kind of file at path is an
+// error. The socket file is removed when the listener is closed.
+func unixListener(path, mode string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm&^0777 != 0 {
+		return nil, fmt.Errorf("Invalid socket mode '%s'", mode)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	mask := syscall.Umask(0777 &^ int(perm))
+	l, err := net.Listen("unix", path)
+	syscall.Umask(mask)
+	return l, err
+}
+
+// describe returns a human-readable address for l for the startup message.
+func describe(l net.Listener) string {
+	addr := l.Addr()
+	if addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	scheme := "http"
+	if certFile != "" {
+		scheme = "https"
+	}
+	return scheme + "://" + addr.String()
+}
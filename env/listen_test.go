@@ -0,0 +1,76 @@
+package env
+
+import (
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type ListenSuite struct {
+	path string
+}
+
+var _ = Suite(&ListenSuite{})
+
+func (s *ListenSuite) SetUpTest(c *C) {
+	s.path = filepath.Join(c.MkDir(), "gadget.sock")
+}
+
+func (s *ListenSuite) TearDownTest(c *C) {
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+}
+
+//unixListener should create the socket with the permissions in -socket-mode
+func (s *ListenSuite) TestUnixlistenerAppliesSocketMode(c *C) {
+	for _, mode := range []os.FileMode{0600, 0660, 0666} {
+		l, err := unixListener(s.path, strconv.FormatUint(uint64(mode), 8))
+		c.Assert(err, IsNil)
+		info, err := os.Stat(s.path)
+		c.Assert(err, IsNil)
+		c.Assert(info.Mode()&os.ModeSocket, Not(Equals), os.FileMode(0))
+		c.Assert(info.Mode().Perm(), Equals, mode)
+		l.Close()
+	}
+	_, err := unixListener(s.path, "rw-rw----")
+	c.Assert(err, ErrorMatches, "Invalid socket mode 'rw-rw----'")
+}
+
+//unixListener should replace a socket file left behind by a previous run
+func (s *ListenSuite) TestUnixlistenerReplacesStaleSocket(c *C) {
+	stale, err := net.Listen("unix", s.path)
+	c.Assert(err, IsNil)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	l, err := unixListener(s.path, "0660")
+	c.Assert(err, IsNil)
+	conn, err := net.Dial("unix", s.path)
+	c.Assert(err, IsNil)
+	conn.Close()
+	l.Close()
+	_, err = os.Stat(s.path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+//unixListener should refuse to replace a file that isn't a socket
+func (s *ListenSuite) TestUnixlistenerRefusesRegularFile(c *C) {
+	c.Assert(ioutil.WriteFile(s.path, []byte("data"), 0600), IsNil)
+	_, err := unixListener(s.path, "0660")
+	c.Assert(err, ErrorMatches, ".* exists and is not a socket")
+	contents, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(string(contents), Equals, "data")
+}
+
+//systemdListener should ignore sockets meant for another process
+func (s *ListenSuite) TestSystemdlistenerIgnoresOtherProcesses(c *C) {
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	l, err := systemdListener()
+	c.Assert(l, IsNil)
+	c.Assert(err, IsNil)
+	c.Assert(os.Getenv("LISTEN_FDS"), Equals, "1")
+}
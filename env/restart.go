@@ -0,0 +1,123 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// The serve command passes the listening socket and a pipe for reporting
+// readiness to the process that replaces it in these environment variables.
+const (
+	listenFdVar = "GADGET_LISTEN_FD"
+	readyFdVar  = "GADGET_READY_FD"
+)
+
+// inheritedListener returns the listener handed down by a serve process that is
+// restarting, or nil if there is none. A unix domain socket created with
+// -socket is removed when this process exits, as it would have been by the
+// process that created it.
+func inheritedListener() (net.Listener, error) {
+	fd, err := strconv.Atoi(os.Getenv(listenFdVar))
+	if err != nil {
+		return nil, nil
+	}
+	os.Unsetenv(listenFdVar)
+	f := os.NewFile(uintptr(fd), listenFdVar)
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to use socket passed by previous process: %s", err)
+	}
+	if ul, ok := l.(*net.UnixListener); ok && socketPath != "" {
+		ul.SetUnlinkOnClose(true)
+	}
+	return l, nil
+}
+
+// notifyReady tells the serve process that started this one, if any, that it
+// is accepting connections, so that the old process can begin draining.
+func notifyReady() {
+	fd, err := strconv.Atoi(os.Getenv(readyFdVar))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(readyFdVar)
+	f := os.NewFile(uintptr(fd), readyFdVar)
+	defer f.Close()
+	f.Write([]byte{1})
+}
+
+// restart starts a new serve process with the same executable, arguments and
+// environment, passing it listener, and waits up to the restart timeout for it
+// to report that it is ready. If it doesn't, the new process is killed and the
+// old one carries on serving.
+func restart(listener net.Listener) error {
+	filer, ok := listener.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return errors.New("listener cannot be passed to a new process")
+	}
+	socket, err := filer.File()
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	executable, err := os.Executable()
+	if err != nil {
+		readyWriter.Close()
+		return err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{socket, readyWriter}
+	cmd.Env = append(os.Environ(), listenFdVar+"=3", readyFdVar+"=4")
+	err = cmd.Start()
+	readyWriter.Close()
+	setNonblock(listener)
+	if err != nil {
+		return err
+	}
+	ready.SetReadDeadline(time.Now().Add(restartTimeout))
+	if _, err := ready.Read(make([]byte, 1)); err != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("new process was not ready after %s", restartTimeout)
+		}
+		return errors.New("new process exited before it was ready")
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	if ul, ok := listener.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	logger.Println("Started new process", pid)
+	return nil
+}
+
+// setNonblock puts listener back into non-blocking mode. Passing a listening
+// socket to a new process puts it into blocking mode, which the copy shares
+// with listener; a blocking accept would keep listener from being closed.
+func setNonblock(listener net.Listener) {
+	conn, ok := listener.(syscall.Conn)
+	if !ok {
+		return
+	}
+	if raw, err := conn.SyscallConn(); err == nil {
+		raw.Control(func(fd uintptr) {
+			syscall.SetNonblock(int(fd), true)
+		})
+	}
+}
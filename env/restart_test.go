@@ -0,0 +1,115 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// helperVar tells a copy of the test binary started by restart to act as the
+// new serve process instead of running the tests.
+const helperVar = "GADGET_RESTART_HELPER"
+
+func init() {
+	switch os.Getenv(helperVar) {
+	case "":
+		return
+	case "ready":
+		l, err := listen()
+		if err != nil {
+			os.Exit(1)
+		}
+		go http.Serve(l, respondWith("new"))
+		notifyReady()
+		time.Sleep(2 * time.Second)
+	case "badcert":
+		l, err := listen()
+		if err != nil {
+			os.Exit(1)
+		}
+		logger = log.New(ioutil.Discard, "", 0)
+		certFile, keyFile = "missing-cert.pem", "missing-key.pem"
+		if err := serve(newServer(), l); err != nil {
+			os.Exit(1)
+		}
+	case "hang":
+		time.Sleep(10 * time.Second)
+	}
+	os.Exit(0)
+}
+
+func respondWith(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+}
+
+type RestartSuite struct {
+	listener net.Listener
+	client   *http.Client
+}
+
+var _ = Suite(&RestartSuite{})
+
+func (s *RestartSuite) SetUpTest(c *C) {
+	logger = log.New(ioutil.Discard, "", 0)
+	restartTimeout = 5 * time.Second
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	s.listener = l
+	s.client = &http.Client{
+		Timeout:   2 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+}
+
+func (s *RestartSuite) TearDownTest(c *C) {
+	os.Unsetenv(helperVar)
+	s.listener.Close()
+}
+
+func (s *RestartSuite) get(c *C) string {
+	resp, err := s.client.Get("http://" + s.listener.Addr().String())
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	return string(body)
+}
+
+//restart should return once the new process reports that it is serving on the inherited listener
+func (s *RestartSuite) TestRestartWaitsForNewProcessToBeReady(c *C) {
+	os.Setenv(helperVar, "ready")
+	c.Assert(restart(s.listener), IsNil)
+	c.Assert(s.get(c), Equals, "new")
+}
+
+//A restart that times out should kill the new process and leave the old listener serving
+func (s *RestartSuite) TestRestartTimeoutLeavesOldListenerServing(c *C) {
+	go http.Serve(s.listener, respondWith("old"))
+	os.Setenv(helperVar, "hang")
+	restartTimeout = 200 * time.Millisecond
+	c.Assert(restart(s.listener), ErrorMatches, "new process was not ready after 200ms")
+	c.Assert(s.get(c), Equals, "old")
+}
+
+//A restart should fail if the new process exits before it is ready
+func (s *RestartSuite) TestRestartFailsIfNewProcessExits(c *C) {
+	go http.Serve(s.listener, respondWith("old"))
+	os.Setenv(helperVar, "exit")
+	c.Assert(restart(s.listener), ErrorMatches, "new process exited before it was ready")
+	c.Assert(s.get(c), Equals, "old")
+}
+
+//A restart should fail if the new process fails to load its TLS certificate
+func (s *RestartSuite) TestRestartFailsIfNewProcessCannotLoadCertificate(c *C) {
+	go http.Serve(s.listener, respondWith("old"))
+	os.Setenv(helperVar, "badcert")
+	c.Assert(restart(s.listener), ErrorMatches, "new process exited before it was ready")
+	c.Assert(s.get(c), Equals, "old")
+}
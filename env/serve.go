@@ -0,0 +1,120 @@
+package env
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	shutdownHooks []func()
+	hooksMutex    sync.Mutex
+)
+
+// OnShutdown registers a func to be called when the serve command shuts down
+// gracefully, after in-flight requests have finished or the drain deadline
+// has passed. Hooks run in the reverse of the order they were registered in,
+// so that resources opened later are released first.
+//
+// 	db := sqlx.MustOpen("postgres", env.Get("DATABASE_URL"))
+// 	env.OnShutdown(func() { db.Close() })
+func OnShutdown(hook func()) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+func runShutdownHooks() {
+	hooksMutex.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	hooksMutex.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// checkTls returns an error if only one of -tls-cert and -tls-key was given.
+func checkTls() error {
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be given together")
+	}
+	return nil
+}
+
+func newServer() *http.Server {
+	return &http.Server{
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+}
+
+// serve runs server on listener until it fails or the process receives SIGTERM
+// or SIGINT, or hands listener to a new process on SIGHUP or SIGUSR2. It
+// returns nil after a graceful shutdown. Closing the listener on shutdown also
+// removes the file for a unix domain socket, unless a new process is using it.
+//
+// The TLS certificate is loaded before the process that started this one, if
+// any, is told that it is ready, so that a restart with a bad certificate
+// leaves the old process serving.
+func serve(server *http.Server, listener net.Listener) error {
+	serving := listener
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		serving = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		})
+	}
+	errs := make(chan error, 1)
+	go func() {
+		Log("Running Gadget at " + describe(listener) + "...")
+		errs <- server.Serve(serving)
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	notifyReady()
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP || sig == syscall.SIGUSR2 {
+				logger.Println("Received", sig.String()+"; restarting...")
+				if err := restart(listener); err != nil {
+					logger.Println("Restart failed:", err)
+					continue
+				}
+			}
+			shutdown(server, sig)
+			return nil
+		}
+	}
+}
+
+// shutdown stops server from accepting connections and waits up to the drain
+// timeout for in-flight requests before closing any that remain and running
+// the shutdown hooks. It logs directly rather than through Log, since the
+// process is about to exit.
+func shutdown(server *http.Server, sig os.Signal) {
+	logger.Println("Received", sig.String()+"; draining connections...")
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Println("Drain deadline passed; closing remaining connections")
+		server.Close()
+	}
+	runShutdownHooks()
+	logger.Println("Gadget stopped")
+}
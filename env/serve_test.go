@@ -0,0 +1,48 @@
+package env
+
+import (
+	. "launchpad.net/gocheck"
+	"testing"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ServeSuite struct{}
+
+var _ = Suite(&ServeSuite{})
+
+func (s *ServeSuite) TearDownTest(c *C) {
+	certFile, keyFile = "", ""
+	shutdownHooks = nil
+}
+
+//Shutdown hooks should run in the reverse of the order they were registered in
+func (s *ServeSuite) TestShutdownHooksRunInReverseOrder(c *C) {
+	var ran []int
+	for i := 1; i <= 3; i++ {
+		i := i
+		OnShutdown(func() { ran = append(ran, i) })
+	}
+	runShutdownHooks()
+	c.Assert(ran, DeepEquals, []int{3, 2, 1})
+}
+
+//Shutdown hooks should only run once
+func (s *ServeSuite) TestShutdownHooksRunOnce(c *C) {
+	var runs int
+	OnShutdown(func() { runs++ })
+	runShutdownHooks()
+	runShutdownHooks()
+	c.Assert(runs, Equals, 1)
+}
+
+//-tls-cert and -tls-key should only be accepted together
+func (s *ServeSuite) TestTlsFlagsAcceptedTogether(c *C) {
+	c.Assert(checkTls(), IsNil)
+	certFile = "cert.pem"
+	c.Assert(checkTls(), ErrorMatches, "-tls-cert and -tls-key must be given together")
+	keyFile = "key.pem"
+	c.Assert(checkTls(), IsNil)
+	certFile = ""
+	c.Assert(checkTls(), ErrorMatches, "-tls-cert and -tls-key must be given together")
+}
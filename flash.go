@@ -0,0 +1,104 @@
+package gadget
+
+import (
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"net/http"
+)
+
+func init() {
+	gob.Register([]Flash{})
+}
+
+// Flash is a message for the user that is displayed on the next page they
+// see, such as "Post saved" after a redirect.
+type Flash struct {
+	Kind, Message string
+}
+
+const flashKey = "_flash"
+
+// FlashCookie is the template for the cookie that carries flash messages when
+// sessions are not in use. Flashes are stored in the session when they are.
+var FlashCookie = http.Cookie{
+	Name:     "gadget_flash",
+	Path:     "/",
+	HttpOnly: true,
+}
+
+// Flash adds a message of the given kind (for example "notice" or "error") to
+// be shown on the next request from the same client, typically the one that
+// follows a redirect. Messages are discarded after that request, whether or
+// not they were shown.
+//
+// 	func (c *PostController) Create(r *gadget.Request) (int, interface{}) {
+// 		...
+// 		r.Flash("notice", "Post saved")
+// 		return 302, "/posts"
+// 	}
+func (r *Request) Flash(kind, message string) {
+	r.flashOut = append(r.flashOut, Flash{kind, message})
+}
+
+// Flashes returns the messages added with Flash during the previous request.
+// If kinds are given, only messages of those kinds are returned.
+func (r *Request) Flashes(kinds ...string) []Flash {
+	if r.flashIn == nil {
+		r.flashIn = r.loadFlashes()
+	}
+	if len(kinds) == 0 {
+		return r.flashIn
+	}
+	var matched []Flash
+	for _, f := range r.flashIn {
+		for _, kind := range kinds {
+			if f.Kind == kind {
+				matched = append(matched, f)
+			}
+		}
+	}
+	return matched
+}
+
+func (r *Request) loadFlashes() []Flash {
+	if sessionStore != nil {
+		flashes, _ := r.Session().Get(flashKey).([]Flash)
+		return flashes
+	}
+	cookie, err := r.Cookie(FlashCookie.Name)
+	if err != nil {
+		return []Flash{}
+	}
+	var flashes []Flash
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err == nil {
+		json.Unmarshal(raw, &flashes)
+	}
+	return flashes
+}
+
+// saveFlashes stores the flashes added during this request for the next one
+// and discards the ones from the previous request. It must run before
+// saveSession.
+func (r *Request) saveFlashes(response *Response) {
+	if sessionStore != nil {
+		sess := r.Session()
+		if len(r.flashOut) > 0 {
+			sess.Set(flashKey, r.flashOut)
+		} else {
+			sess.Delete(flashKey)
+		}
+		return
+	}
+	cookie := FlashCookie
+	if len(r.flashOut) > 0 {
+		raw, _ := json.Marshal(r.flashOut)
+		cookie.Value = base64.RawURLEncoding.EncodeToString(raw)
+	} else if _, err := r.Cookie(FlashCookie.Name); err == nil {
+		cookie.MaxAge = -1
+	} else {
+		return
+	}
+	response.AddCookie(&cookie)
+}
@@ -0,0 +1,81 @@
+package gadget
+
+import (
+	"fmt"
+	"github.com/redneckbeard/gadget/session"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type FlashSuite struct{}
+
+type flashApp struct {
+	*App
+}
+
+var fa *flashApp
+
+var _ = Suite(&FlashSuite{})
+
+func (s *FlashSuite) SetUpTest(c *C) {
+	fa = &flashApp{&App{}}
+	fa.Register(&NoticeController{})
+	fa.Routes(fa.Resource("notices"))
+}
+
+func (s *FlashSuite) TearDownTest(c *C) {
+	fa.Controllers = make(map[string]Controller)
+	StoreSessionsWith(nil)
+}
+
+type NoticeController struct {
+	*DefaultController
+}
+
+func (c *NoticeController) Index(r *Request) (int, interface{}) {
+	return 200, fmt.Sprint(r.Flashes(), r.Flashes("error"))
+}
+
+func (c *NoticeController) Create(r *Request) (int, interface{}) {
+	r.Flash("notice", "Notice saved")
+	r.Flash("error", "But not really")
+	return 302, "/notices"
+}
+
+// follow makes a request with the cookies in jar and updates jar with the
+// cookies from the response.
+func (s *FlashSuite) follow(method string, jar map[string]*http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/notices", nil)
+	for _, cookie := range jar {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	fa.Handler()(resp, req)
+	for _, cookie := range (&http.Response{Header: resp.Header()}).Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(jar, cookie.Name)
+		} else {
+			jar[cookie.Name] = cookie
+		}
+	}
+	return resp
+}
+
+func (s *FlashSuite) assertOneRedirect(c *C) {
+	jar := make(map[string]*http.Cookie)
+	c.Assert(s.follow("POST", jar).Code, Equals, 302)
+	c.Assert(s.follow("GET", jar).Body.String(), Equals, "[{notice Notice saved} {error But not really}] [{error But not really}]")
+	c.Assert(s.follow("GET", jar).Body.String(), Equals, "[] []")
+}
+
+//Flashes survive exactly one redirect in a cookie
+func (s *FlashSuite) TestCookieFlashes(c *C) {
+	s.assertOneRedirect(c)
+}
+
+//Flashes survive exactly one redirect in the session when sessions are on
+func (s *FlashSuite) TestSessionFlashes(c *C) {
+	StoreSessionsWith(session.NewCookieStore([]byte("secret")))
+	s.assertOneRedirect(c)
+}
@@ -1,9 +1,11 @@
 package forms
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	. "launchpad.net/gocheck"
+	"mime/multipart"
 	"time"
 )
 
@@ -192,3 +194,55 @@ func (s *FieldSuite) TestTimefieldShouldSetTimetimeWhenCleaningStringThatMatches
 	c.Assert(field.Error(), IsNil)
 	c.Assert(field.Value, Equals, t)
 }
+
+func fileHeader(c *C, name string, content []byte) *multipart.FileHeader {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	part, err := w.CreateFormFile("file", name)
+	c.Assert(err, IsNil)
+	part.Write(content)
+	w.Close()
+	form, err := multipart.NewReader(buf, w.Boundary()).ReadForm(1 << 20)
+	c.Assert(err, IsNil)
+	return form.File["file"][0]
+}
+
+var pngBytes = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+//A FileField should set the file header and sniffed MIME type as its value
+func (s *FieldSuite) TestFilefieldShouldSetFileheaderWhenCleaningAllowedFile(c *C) {
+	field := &FileField{BaseField: newBaseField(), Types: []string{"image/*"}}
+	field.DefaultMessages()
+	field.Set(fileHeader(c, "a.png", pngBytes))
+	field.Clean()
+	c.Assert(field.Error(), IsNil)
+	c.Assert(field.Value.Filename, Equals, "a.png")
+	c.Assert(field.ContentType, Equals, "image/png")
+}
+
+//A FileField should return an error when cleaning a file of a disallowed type
+func (s *FieldSuite) TestFilefieldShouldReturnErrorWhenCleaningDisallowedType(c *C) {
+	field := &FileField{BaseField: newBaseField(), Types: []string{"image/png"}}
+	field.DefaultMessages()
+	field.Set(fileHeader(c, "a.png", []byte("just text, really")))
+	field.Clean()
+	c.Assert(field.Error(), ErrorMatches, "Files of type text/plain are not allowed")
+}
+
+//A FileField should return an error when cleaning a file larger than MaxSize
+func (s *FieldSuite) TestFilefieldShouldReturnErrorWhenCleaningLargeFile(c *C) {
+	field := &FileField{BaseField: newBaseField(), MaxSize: 4}
+	field.DefaultMessages()
+	field.Set(fileHeader(c, "a.png", pngBytes))
+	field.Clean()
+	c.Assert(field.Error(), ErrorMatches, "Files may be no larger than 4 bytes")
+}
+
+//A FileField should return an error when cleaning something other than a file
+func (s *FieldSuite) TestFilefieldShouldReturnErrorWhenCleaningString(c *C) {
+	field := &FileField{BaseField: newBaseField()}
+	field.DefaultMessages()
+	field.Set("a.png")
+	field.Clean()
+	c.Assert(field.Error(), ErrorMatches, "A file is required")
+}
@@ -3,8 +3,12 @@ package forms
 import (
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,9 +24,9 @@ import (
 // unless their Required field is set to false in the form's SetOptions method.
 //
 // The forms package ships with FormField types for string, int, bool, float64,
-// and time.Time values. Users can easily define additional fields. Refer to
-// the source for the builtin FormField types for details of how to define new
-// ones.
+// and time.Time values, and for uploaded files. Users can easily define
+// additional fields. Refer to the source for the builtin FormField types for
+// details of how to define new ones.
 type FormField interface {
 	Clean()
 	Copy(reflect.Value)
@@ -318,3 +322,85 @@ func (field *TimeField) Copy(v reflect.Value) {
 func (field *TimeField) DefaultMessages() {
 	field.SetMessage("type", `An integer of seconds since the epoch or a string in the format "%s" is required`)
 }
+
+// FileField validates a file uploaded in a multipart/form-data request, as
+// found in gadget.Request.Files. Its Data should be a *multipart.FileHeader,
+// which can be set with the field's Set method:
+//
+// 	form.Avatar.Set(r.Files.Get("avatar"))
+//
+// If MaxSize is non-zero, files larger than MaxSize bytes are rejected. If
+// Types is non-empty, the MIME type of the file, sniffed from its contents
+// rather than taken from the client, must match one of its entries; entries
+// may end in "/*" to allow any subtype. Both can be set with struct tags:
+//
+// 	Avatar *FileField `maxsize:"1048576" types:"image/png,image/jpeg"`
+type FileField struct {
+	*BaseField
+	Value       *multipart.FileHeader
+	ContentType string
+	MaxSize     int64
+	Types       []string
+}
+
+func (field *FileField) isNil() bool {
+	header, ok := field.Data.(*multipart.FileHeader)
+	return field.Data == nil || (ok && header == nil)
+}
+
+func (field *FileField) Clean() {
+	header, ok := field.Data.(*multipart.FileHeader)
+	if !ok || header == nil {
+		field.SetError(field.GetMessage("type"))
+		return
+	}
+	if field.MaxSize > 0 && header.Size > field.MaxSize {
+		field.SetError(fmt.Sprintf(field.GetMessage("size"), field.MaxSize))
+		return
+	}
+	contentType, err := sniffContentType(header)
+	if err != nil {
+		field.SetError(field.GetMessage("type"))
+		return
+	}
+	if len(field.Types) > 0 && !mimeAllowed(contentType, field.Types) {
+		field.SetError(fmt.Sprintf(field.GetMessage("mime"), contentType))
+		return
+	}
+	field.Value = header
+	field.ContentType = contentType
+}
+
+func sniffContentType(header *multipart.FileHeader) (string, error) {
+	f, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return strings.Split(http.DetectContentType(buf[:n]), ";")[0], nil
+}
+
+func mimeAllowed(contentType string, types []string) bool {
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (field *FileField) Copy(v reflect.Value) {
+	v.Set(reflect.ValueOf(field.Value))
+}
+
+func (field *FileField) DefaultMessages() {
+	field.SetMessage("type", "A file is required")
+	field.SetMessage("size", "Files may be no larger than %d bytes")
+	field.SetMessage("mime", "Files of type %s are not allowed")
+}
@@ -81,6 +81,15 @@ func Init(form Form, initializer func(Form)) {
 				formatField := newField.Elem().FieldByName("Format")
 				formatField.SetString(tags.Get("format"))
 			}
+			if tags.Get("maxsize") != "" {
+				if size, err := strconv.ParseInt(tags.Get("maxsize"), 10, 64); err == nil {
+					newField.Elem().FieldByName("MaxSize").SetInt(size)
+				}
+			}
+			if tags.Get("types") != "" {
+				types := strings.Split(tags.Get("types"), ",")
+				newField.Elem().FieldByName("Types").Set(reflect.ValueOf(types))
+			}
 			fv.Set(newField)
 			formField := fv.Interface().(FormField)
 			formField.DefaultMessages()
@@ -179,3 +188,20 @@ func Copy(f Form, target interface{}) error {
 	}
 	return nil
 }
+
+// SetErrors records errors that were found outside of the form's own
+// validation, such as the gadget.BindErrors returned by Request.Bind. Errors
+// are matched to FormFields by name; errors for names that the form has no
+// field for are still added to the Errors map.
+func SetErrors(f Form, errs map[string]error) {
+	if !f.ready() {
+		return
+	}
+	fields := f.fieldMap()
+	for name, err := range errs {
+		if field, ok := fields[name]; ok {
+			field.SetError(err.Error())
+		}
+		f.errorMap()[name] = err
+	}
+}
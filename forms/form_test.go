@@ -1,7 +1,9 @@
 package forms
 
 import (
+	"errors"
 	. "launchpad.net/gocheck"
+	"mime/multipart"
 	"testing"
 )
 
@@ -188,3 +190,36 @@ func (s *FormSuite) TestCallingCopyOnInvalidWidgetformAndWidgetShouldCopyValuesT
 	err := Copy(form, widget)
 	c.Assert(err, ErrorMatches, "Cannot copy from invalid form")
 }
+
+//Calling SetErrors on a Form should set errors on the named fields
+func (s *FormSuite) TestCallingSetErrorsShouldSetErrorsOnFields(c *C) {
+	f := &WidgetForm{}
+	Init(f, nil)
+	SetErrors(f, map[string]error{"Baz": errors.New(`"x" is not an integer`)})
+	c.Assert(f.HasErrors(), Equals, true)
+	c.Assert(f.Baz.Error(), ErrorMatches, `"x" is not an integer`)
+	c.Assert(f.Errors["Baz"], ErrorMatches, `"x" is not an integer`)
+}
+
+type UploadForm struct {
+	*DefaultForm
+	Avatar *FileField `maxsize:"1024" types:"image/png,image/gif"`
+}
+
+//Init should set MaxSize and Types on FileFields from struct tags
+func (s *FormSuite) TestInitSetsFilefieldOptionsFromTags(c *C) {
+	f := &UploadForm{}
+	Init(f, nil)
+	c.Assert(f.Avatar.MaxSize, Equals, int64(1024))
+	c.Assert(f.Avatar.Types, DeepEquals, []string{"image/png", "image/gif"})
+}
+
+//A required FileField with no file should not be valid
+func (s *FormSuite) TestMissingRequiredFileIsInvalid(c *C) {
+	f := &UploadForm{}
+	Init(f, nil)
+	var header *multipart.FileHeader
+	f.Avatar.Set(header)
+	c.Assert(IsValid(f), Equals, false)
+	c.Assert(f.Errors["Avatar"], ErrorMatches, "This field is required")
+}
@@ -19,6 +19,7 @@ func (app *gdgt) Configure() error {
 		app.SetIndex("home"),
 	)
 	app.Accept("application/json").Via(gadget.JsonBroker)
+	app.Accept(gadget.ProblemMimeType).Via(gadget.ProblemBroker)
 	app.Accept("text/html", "*/*").Via(templates.TemplateBroker)
 	return nil
 }
@@ -0,0 +1,148 @@
+package gadget
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity is the User returned by Gadget's built-in UserIdentifiers. Id is
+// the username, token owner, API key id, or JWT subject, and Method names the
+// identifier that produced it ("basic", "bearer", "apikey", or "jwt"). Claims
+// holds the claims of a JWT and is nil otherwise.
+type Identity struct {
+	Id     string
+	Method string
+	Claims map[string]interface{}
+}
+
+// Authenticated for Identity is always true.
+func (i *Identity) Authenticated() bool { return true }
+
+// AnyOf returns a UserIdentifier that tries each of identifiers in turn and
+// returns the first authenticated User, or nil if none of them identify the
+// requester.
+//
+// 	gadget.IdentifyUsersWith(gadget.AnyOf(
+// 		gadget.JwtAuth(gadget.JwtConfig{HmacKey: key}),
+// 		gadget.ApiKeyAuth(lookupSecret),
+// 		gadget.SessionUser("user_id", findUser),
+// 	))
+func AnyOf(identifiers ...UserIdentifier) UserIdentifier {
+	return func(r *Request) User {
+		for _, identify := range identifiers {
+			if user := identify(r); user != nil && user.Authenticated() {
+				return user
+			}
+		}
+		return nil
+	}
+}
+
+// BasicAuth returns a UserIdentifier for HTTP Basic authentication that
+// accepts the credentials for which check returns true.
+func BasicAuth(check func(username, password string) bool) UserIdentifier {
+	return func(r *Request) User {
+		username, password, ok := r.BasicAuth()
+		if !ok || !check(username, password) {
+			return nil
+		}
+		return &Identity{Id: username, Method: "basic"}
+	}
+}
+
+func bearerToken(r *Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// BearerAuth returns a UserIdentifier for opaque tokens sent in an
+// "Authorization: Bearer" header. check returns the id of the token's owner
+// and whether the token is valid.
+func BearerAuth(check func(token string) (id string, ok bool)) UserIdentifier {
+	return func(r *Request) User {
+		token := bearerToken(r)
+		if token == "" {
+			return nil
+		}
+		id, ok := check(token)
+		if !ok {
+			return nil
+		}
+		return &Identity{Id: id, Method: "bearer"}
+	}
+}
+
+// ApiKeyMaxSkew is how far the timestamp of a request signed for ApiKeyAuth
+// may be from the server's clock.
+var ApiKeyMaxSkew = 5 * time.Minute
+
+// Headers used by ApiKeyAuth and SignRequest.
+const (
+	ApiKeyHeader       = "X-Api-Key"
+	ApiTimestampHeader = "X-Api-Timestamp"
+	ApiSignatureHeader = "X-Api-Signature"
+)
+
+// apiSignature computes the hex-encoded HMAC-SHA256 of the method, request
+// URI, timestamp, and SHA-256 of the body, separated by newlines.
+func apiSignature(secret []byte, method, uri, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%x", method, uri, timestamp, bodyHash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs req for ApiKeyAuth with the key identified by keyId,
+// which must have secret as its secret. body must be the request's body.
+func SignRequest(req *http.Request, keyId string, secret, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(ApiKeyHeader, keyId)
+	req.Header.Set(ApiTimestampHeader, timestamp)
+	req.Header.Set(ApiSignatureHeader, apiSignature(secret, req.Method, req.URL.RequestURI(), timestamp, body))
+}
+
+// ApiKeyAuth returns a UserIdentifier for requests signed with SignRequest.
+// secret returns the secret for a key id, or nil if there is no such key.
+// Requests must carry the key id, a Unix timestamp within ApiKeyMaxSkew of
+// the present, and an HMAC-SHA256 signature covering the method, URI,
+// timestamp, and body. Multipart bodies are not covered by the signature, and
+// requests with bodies larger than MaxBodySize are not identified.
+func ApiKeyAuth(secret func(keyId string) []byte) UserIdentifier {
+	return func(r *Request) User {
+		keyId := r.Header.Get(ApiKeyHeader)
+		if keyId == "" {
+			return nil
+		}
+		timestamp := r.Header.Get(ApiTimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil
+		}
+		if skew := time.Since(time.Unix(seconds, 0)); skew > ApiKeyMaxSkew || skew < -ApiKeyMaxSkew {
+			return nil
+		}
+		key := secret(keyId)
+		if key == nil {
+			return nil
+		}
+		body, err := r.rawBody()
+		if err != nil {
+			return nil
+		}
+		expected := apiSignature(key, r.Method, r.URL.RequestURI(), timestamp, body)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(r.Header.Get(ApiSignatureHeader))) != 1 {
+			return nil
+		}
+		return &Identity{Id: keyId, Method: "apikey"}
+	}
+}
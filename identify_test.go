@@ -0,0 +1,187 @@
+package gadget
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IdentifySuite struct{}
+
+var _ = Suite(&IdentifySuite{})
+
+func identified(identify UserIdentifier, r *Request) *Identity {
+	user := identify(r)
+	if user == nil {
+		return nil
+	}
+	return user.(*Identity)
+}
+
+func authRequest(authorization string) *Request {
+	r := bindRequest("GET", "http://127.0.0.1:8000/widgets", "", "")
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	return r
+}
+
+//BasicAuth identifies users whose credentials pass the check
+func (s *IdentifySuite) TestBasicAuth(c *C) {
+	identify := BasicAuth(func(username, password string) bool {
+		return username == "jo" && password == "hunter2"
+	})
+	creds := func(s string) string { return "Basic " + base64.StdEncoding.EncodeToString([]byte(s)) }
+	c.Assert(identified(identify, authRequest(creds("jo:hunter2"))), DeepEquals, &Identity{Id: "jo", Method: "basic"})
+	c.Assert(identified(identify, authRequest(creds("jo:hunter3"))), IsNil)
+	c.Assert(identified(identify, authRequest("")), IsNil)
+}
+
+//BearerAuth identifies the owners of valid tokens
+func (s *IdentifySuite) TestBearerAuth(c *C) {
+	identify := BearerAuth(func(token string) (string, bool) {
+		return "jo", token == "s3cret"
+	})
+	c.Assert(identified(identify, authRequest("Bearer s3cret")).Id, Equals, "jo")
+	c.Assert(identified(identify, authRequest("Bearer wrong")), IsNil)
+	c.Assert(identified(identify, authRequest("Basic s3cret")), IsNil)
+}
+
+//ApiKeyAuth identifies requests signed with SignRequest
+func (s *IdentifySuite) TestApiKeyAuth(c *C) {
+	secrets := map[string][]byte{"key1": []byte("secret")}
+	identify := ApiKeyAuth(func(keyId string) []byte { return secrets[keyId] })
+
+	signed := func(body string, tamper func(*http.Request)) *Request {
+		raw, _ := http.NewRequest("POST", "http://127.0.0.1:8000/widgets?x=1", strings.NewReader(body))
+		raw.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		SignRequest(raw, "key1", secrets["key1"], []byte(body))
+		if tamper != nil {
+			tamper(raw)
+		}
+		r := newRequest(raw)
+		r.parseBody(MaxBodySize)
+		return r
+	}
+	c.Assert(identified(identify, signed("name=widget", nil)), DeepEquals, &Identity{Id: "key1", Method: "apikey"})
+	c.Assert(identified(identify, signed("name=widget", func(r *http.Request) {
+		r.Body = http.NoBody
+		r.ContentLength = 0
+	})), IsNil)
+	c.Assert(identified(identify, signed("name=widget", func(r *http.Request) {
+		r.URL.RawQuery = "x=2"
+	})), IsNil)
+	c.Assert(identified(identify, signed("name=widget", func(r *http.Request) {
+		r.Header.Set(ApiKeyHeader, "key2")
+	})), IsNil)
+	c.Assert(identified(identify, signed("name=widget", func(r *http.Request) {
+		old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+		r.Header.Set(ApiTimestampHeader, old)
+		r.Header.Set(ApiSignatureHeader, apiSignature(secrets["key1"], "POST", "/widgets?x=1", old, []byte("name=widget")))
+	})), IsNil)
+}
+
+//ApiKeyAuth reads no more than MaxBodySize bytes of the body and leaves the rest for the action
+func (s *IdentifySuite) TestApiKeyAuthLimitsBody(c *C) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 16
+	secrets := map[string][]byte{"key1": []byte("secret")}
+	identify := ApiKeyAuth(func(keyId string) []byte { return secrets[keyId] })
+	body := "name=" + strings.Repeat("w", 100)
+	raw, _ := http.NewRequest("POST", "http://127.0.0.1:8000/widgets", strings.NewReader(body))
+	SignRequest(raw, "key1", secrets["key1"], []byte(body))
+	r := newRequest(raw)
+	c.Assert(identified(identify, r), IsNil)
+	c.Assert(r.raw, IsNil)
+	rest, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(rest), Equals, body)
+}
+
+func jwtSegment(v interface{}) string {
+	raw, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func hs256(key []byte, claims map[string]interface{}) string {
+	signed := jwtSegment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + jwtSegment(claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//JwtAuth verifies HS256 tokens and exposes their claims
+func (s *IdentifySuite) TestJwtHs256(c *C) {
+	key := []byte("jwt-secret")
+	identify := JwtAuth(JwtConfig{HmacKey: key, Issuer: "gadget", Audience: "api"})
+	future := time.Now().Add(time.Hour).Unix()
+	claims := map[string]interface{}{"sub": "jo", "iss": "gadget", "aud": []string{"api", "web"}, "exp": future, "admin": true}
+
+	identity := identified(identify, authRequest("Bearer "+hs256(key, claims)))
+	c.Assert(identity, NotNil)
+	c.Assert(identity.Id, Equals, "jo")
+	c.Assert(identity.Method, Equals, "jwt")
+	c.Assert(identity.Claims["admin"], Equals, true)
+
+	c.Assert(identified(identify, authRequest("Bearer "+hs256([]byte("other"), claims))), IsNil)
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	c.Assert(identified(identify, authRequest("Bearer "+hs256(key, claims))), IsNil)
+	claims["exp"] = future
+	claims["iss"] = "someone-else"
+	c.Assert(identified(identify, authRequest("Bearer "+hs256(key, claims))), IsNil)
+	claims["iss"] = "gadget"
+	claims["aud"] = "web"
+	c.Assert(identified(identify, authRequest("Bearer "+hs256(key, claims))), IsNil)
+	claims["aud"] = "api"
+	claims["nbf"] = future
+	c.Assert(identified(identify, authRequest("Bearer "+hs256(key, claims))), IsNil)
+}
+
+//JwtAuth verifies RS256 tokens and refuses other algorithms
+func (s *IdentifySuite) TestJwtRs256(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	identify := JwtAuth(JwtConfig{RsaKey: &key.PublicKey})
+	claims := map[string]interface{}{"sub": "jo"}
+
+	signed := jwtSegment(map[string]string{"alg": "RS256"}) + "." + jwtSegment(claims)
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	c.Assert(err, IsNil)
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	c.Assert(identified(identify, authRequest("Bearer "+token)).Id, Equals, "jo")
+
+	unsigned := jwtSegment(map[string]string{"alg": "none"}) + "." + jwtSegment(claims) + "."
+	c.Assert(identified(identify, authRequest("Bearer "+unsigned)), IsNil)
+	c.Assert(identified(identify, authRequest("Bearer "+hs256([]byte("x"), claims))), IsNil)
+}
+
+//AnyOf returns the first authenticated User
+func (s *IdentifySuite) TestAnyOf(c *C) {
+	identify := AnyOf(
+		func(r *Request) User { return &AnonymousUser{} },
+		BearerAuth(func(token string) (string, bool) { return "bearer", token == "a" }),
+		BearerAuth(func(token string) (string, bool) { return "fallback", true }),
+	)
+	c.Assert(identified(identify, authRequest("Bearer a")).Id, Equals, "bearer")
+	c.Assert(identified(identify, authRequest("Bearer b")).Id, Equals, "fallback")
+	c.Assert(identify(authRequest("")), IsNil)
+}
+
+//Requests get an AnonymousUser when the UserIdentifier returns nil
+func (s *IdentifySuite) TestNilUserIsAnonymous(c *C) {
+	IdentifyUsersWith(BearerAuth(func(token string) (string, bool) { return "", false }))
+	defer clearUserIdentifier()
+	r := authRequest("")
+	c.Assert(r.User().Authenticated(), Equals, false)
+}
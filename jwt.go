@@ -0,0 +1,125 @@
+package gadget
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// JwtConfig configures JwtAuth. Tokens signed with HS256 are accepted if
+// HmacKey is set, and tokens signed with RS256 if RsaKey is; tokens using any
+// other algorithm, including "none", are always rejected. If Issuer or
+// Audience are set, the "iss" claim must equal Issuer and the "aud" claim must
+// contain Audience. Leeway allows for clock skew when checking the "exp" and
+// "nbf" claims.
+type JwtConfig struct {
+	HmacKey  []byte
+	RsaKey   *rsa.PublicKey
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+var errInvalidJwt = errors.New("invalid JWT")
+
+// JwtAuth returns a UserIdentifier for JSON Web Tokens sent in an
+// "Authorization: Bearer" header, verified locally according to config. The
+// Identity it returns has the "sub" claim as its Id and all of the token's
+// claims as its Claims.
+func JwtAuth(config JwtConfig) UserIdentifier {
+	return func(r *Request) User {
+		token := bearerToken(r)
+		if token == "" {
+			return nil
+		}
+		claims, err := config.verify(token, time.Now())
+		if err != nil {
+			return nil
+		}
+		sub, _ := claims["sub"].(string)
+		return &Identity{Id: sub, Method: "jwt", Claims: claims}
+	}
+}
+
+func decodeJwtSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errInvalidJwt
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errInvalidJwt
+	}
+	return nil
+}
+
+func (config JwtConfig) verify(token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidJwt
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJwtSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidJwt
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && config.HmacKey != nil:
+		mac := hmac.New(sha256.New, config.HmacKey)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errInvalidJwt
+		}
+	case header.Alg == "RS256" && config.RsaKey != nil:
+		hash := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(config.RsaKey, crypto.SHA256, hash[:], signature) != nil {
+			return nil, errInvalidJwt
+		}
+	default:
+		return nil, errInvalidJwt
+	}
+	claims := make(map[string]interface{})
+	if err := decodeJwtSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	leeway := config.Leeway.Seconds()
+	unix := float64(now.Unix())
+	if exp, ok := claims["exp"].(float64); ok && unix > exp+leeway {
+		return nil, errInvalidJwt
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && unix < nbf-leeway {
+		return nil, errInvalidJwt
+	}
+	if config.Issuer != "" && claims["iss"] != config.Issuer {
+		return nil, errInvalidJwt
+	}
+	if config.Audience != "" && !audienceContains(claims["aud"], config.Audience) {
+		return nil, errInvalidJwt
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
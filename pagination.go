@@ -0,0 +1,145 @@
+package gadget
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	// PageParam and PerPageParam are the names of the request parameters read
+	// by Paginate.
+	PageParam    = "page"
+	PerPageParam = "per_page"
+	// MaxPerPage caps the page size a client can request.
+	MaxPerPage = 100
+)
+
+// Page describes one page of a paginated collection. Controller methods
+// create a Page with Paginate, use its Limit and Offset to fetch the current
+// page, fill in Items and Total, and return the Page as the body. JsonBroker
+// serializes only the Items; the App adds RFC 8288 Link headers and an
+// X-Total-Count header to JSON responses. Templates rendered by
+// TemplateBroker receive the Page and can use PrevUrl and NextUrl.
+type Page struct {
+	Number, PerPage, Total int
+	Items                  interface{}
+	url                    *url.URL
+}
+
+// Paginate reads the page and per_page parameters from the request and
+// returns a Page for them. perPage is the page size used when the client
+// doesn't ask for one.
+func Paginate(r *Request, perPage int) *Page {
+	page := &Page{Number: 1, PerPage: perPage}
+	if n, err := strconv.Atoi(paramString(r, PageParam)); err == nil && n > 0 {
+		page.Number = n
+	}
+	if n, err := strconv.Atoi(paramString(r, PerPageParam)); err == nil && n > 0 {
+		page.PerPage = n
+	}
+	if page.PerPage > MaxPerPage {
+		page.PerPage = MaxPerPage
+	}
+	if page.PerPage < 1 {
+		page.PerPage = 1
+	}
+	if r.Request != nil && r.URL != nil {
+		u := *r.URL
+		page.url = &u
+	}
+	return page
+}
+
+func paramString(r *Request, name string) string {
+	s, _ := r.Params[name].(string)
+	return s
+}
+
+// Limit returns the number of items on the page, for use in a LIMIT clause.
+func (p *Page) Limit() int { return p.PerPage }
+
+// Offset returns the number of items before the page, for use in an OFFSET
+// clause.
+func (p *Page) Offset() int { return (p.Number - 1) * p.PerPage }
+
+// Pages returns the total number of pages given Total.
+func (p *Page) Pages() int {
+	if p.Total <= 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// HasPrev returns true if there is a page before this one.
+func (p *Page) HasPrev() bool { return p.Number > 1 }
+
+// HasNext returns true if there is a page after this one.
+func (p *Page) HasNext() bool { return p.Number < p.Pages() }
+
+// PrevUrl returns the URL of the previous page, or "" if there isn't one.
+func (p *Page) PrevUrl() string {
+	if !p.HasPrev() {
+		return ""
+	}
+	return p.Url(p.Number - 1)
+}
+
+// NextUrl returns the URL of the next page, or "" if there isn't one.
+func (p *Page) NextUrl() string {
+	if !p.HasNext() {
+		return ""
+	}
+	return p.Url(p.Number + 1)
+}
+
+// Url returns the URL of the request that created the Page with its page
+// parameter set to number.
+func (p *Page) Url(number int) string {
+	u := &url.URL{}
+	if p.url != nil {
+		copied := *p.url
+		u = &copied
+	}
+	query := u.Query()
+	query.Set(PageParam, strconv.Itoa(number))
+	query.Set(PerPageParam, strconv.Itoa(p.PerPage))
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// Links returns the value of an RFC 8288 Link header with first, prev, next,
+// and last relations as appropriate.
+func (p *Page) Links() string {
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, p.Url(1))}
+	if p.HasPrev() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, p.PrevUrl()))
+	}
+	if p.HasNext() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, p.NextUrl()))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, p.Url(p.Pages())))
+	return strings.Join(links, ", ")
+}
+
+// MarshalJSON serializes only the Items of the Page.
+func (p *Page) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Items)
+}
+
+func (p *Page) meta() map[string]interface{} {
+	return map[string]interface{}{
+		"number":   p.Number,
+		"per_page": p.PerPage,
+		"total":    p.Total,
+		"pages":    p.Pages(),
+	}
+}
+
+func (p *Page) setHeaders(h http.Header) {
+	h.Set("Link", p.Links())
+	h.Set("X-Total-Count", strconv.Itoa(p.Total))
+}
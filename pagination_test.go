@@ -0,0 +1,96 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type PaginationSuite struct{}
+
+type paginationApp struct {
+	*App
+}
+
+var pga *paginationApp
+
+var _ = Suite(&PaginationSuite{})
+
+func (s *PaginationSuite) SetUpSuite(c *C) {
+	pga = &paginationApp{&App{}}
+	pga.Register(&PagedController{})
+	pga.Accept("application/json").Via(JsonBroker)
+	pga.Routes(pga.Resource("pageds"))
+}
+
+func (s *PaginationSuite) TearDownSuite(c *C) {
+	pga.Controllers = make(map[string]Controller)
+}
+
+type PagedController struct {
+	*DefaultController
+}
+
+func (c *PagedController) Index(r *Request) (int, interface{}) {
+	page := Paginate(r, 2)
+	page.Total = 5
+	items := []int{}
+	for i := page.Offset(); i < page.Offset()+page.Limit() && i < page.Total; i++ {
+		items = append(items, i)
+	}
+	page.Items = items
+	return 200, page
+}
+
+func paginated(c *C, rawurl string) *Page {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	c.Assert(err, IsNil)
+	r := newRequest(req)
+	r.parseBody(MaxBodySize)
+	return Paginate(r, 10)
+}
+
+//Paginate defaults to the first page of the given size
+func (s *PaginationSuite) TestPaginateDefaults(c *C) {
+	page := paginated(c, "http://127.0.0.1:8000/pageds")
+	c.Assert(page.Number, Equals, 1)
+	c.Assert(page.Limit(), Equals, 10)
+	c.Assert(page.Offset(), Equals, 0)
+}
+
+//Paginate reads page and per_page, ignoring garbage and capping the page size
+func (s *PaginationSuite) TestPaginateParams(c *C) {
+	page := paginated(c, "http://127.0.0.1:8000/pageds?page=3&per_page=5")
+	c.Assert(page.Offset(), Equals, 10)
+	c.Assert(page.Limit(), Equals, 5)
+	page = paginated(c, "http://127.0.0.1:8000/pageds?page=-1&per_page=5000")
+	c.Assert(page.Number, Equals, 1)
+	c.Assert(page.Limit(), Equals, MaxPerPage)
+}
+
+//Links includes prev and next only when those pages exist, and keeps other query params
+func (s *PaginationSuite) TestLinks(c *C) {
+	page := paginated(c, "http://127.0.0.1:8000/pageds?q=cats&page=2")
+	page.Total = 25
+	c.Assert(page.Links(), Equals, `</pageds?page=1&per_page=10&q=cats>; rel="first", </pageds?page=1&per_page=10&q=cats>; rel="prev", </pageds?page=3&per_page=10&q=cats>; rel="next", </pageds?page=3&per_page=10&q=cats>; rel="last"`)
+	page.Number = 3
+	c.Assert(page.NextUrl(), Equals, "")
+}
+
+//A Page returned to a JSON client is serialized as its items with Link and X-Total-Count headers
+func (s *PaginationSuite) TestJsonResponse(c *C) {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/pageds?page=3", nil)
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	pga.Handler()(resp, req)
+	c.Assert(resp.Body.String(), Equals, "[4]")
+	c.Assert(resp.Header().Get("X-Total-Count"), Equals, "5")
+	c.Assert(resp.Header().Get("Link"), Equals, `</pageds?page=1&per_page=2>; rel="first", </pageds?page=2&per_page=2>; rel="prev", </pageds?page=3&per_page=2>; rel="last"`)
+}
+
+//An enveloped JSON broker puts the pagination in the meta object
+func (s *PaginationSuite) TestEnvelopedPage(c *C) {
+	page := &Page{Number: 1, PerPage: 2, Total: 3, Items: []int{0, 1}}
+	_, body := NewJsonBroker(JsonOptions{Envelope: true})(&Request{}, 200, page, nil)
+	c.Assert(body, Equals, `{"data":[0,1],"meta":{"page":{"number":1,"pages":2,"per_page":2,"total":3},"status":200}}`)
+}
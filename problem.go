@@ -0,0 +1,124 @@
+package gadget
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ProblemMimeType is the media type defined by RFC 7807 for JSON problem
+// details.
+const ProblemMimeType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Controller methods and
+// Filters can return a *Problem as their body (directly or wrapped in a
+// Response) to describe an error in a machine-readable way. Any members in
+// Extensions are serialized alongside the standard members.
+type Problem struct {
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a *Problem for the HTTP status code with its Title set to
+// the standard status text and its Detail set to detail.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Set adds an extension member to the Problem and returns the Problem so that
+// calls can be chained.
+func (p *Problem) Set(name string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[name] = value
+	return p
+}
+
+// Error allows a *Problem to be used as an error value.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%s: %s", p.Title, p.Detail)
+	}
+	return p.Title
+}
+
+// MarshalJSON flattens Extensions into the top-level JSON object. Standard
+// members take precedence over extensions with the same name.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{})
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	type problem Problem
+	standard, err := json.Marshal((*problem)(p))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(standard, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// ProblemBroker serializes a *Problem as application/problem+json. Bodies that
+// are not a *Problem are wrapped in one built from the status code, with any
+// non-empty body used as the detail.
+func ProblemBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	problem, ok := body.(*Problem)
+	if !ok {
+		problem = NewProblem(status, "")
+		if !isBlank(body) {
+			problem.Detail = fmt.Sprint(body)
+		}
+	}
+	if problem.Status == 0 {
+		problem.Status = status
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	return JsonBroker(r, status, problem, data)
+}
+
+// problemStatuses are the statuses for which Gadget generates a Problem when a
+// controller or the router produces no body of its own.
+var problemStatuses = map[int]bool{
+	400: true,
+	401: true,
+	403: true,
+	404: true,
+	405: true,
+	406: true,
+	413: true,
+	415: true,
+	429: true,
+	500: true,
+}
+
+func isBlank(body interface{}) bool {
+	if body == nil {
+		return true
+	}
+	s, ok := body.(string)
+	return ok && s == ""
+}
+
+// problemFor returns a Problem for error responses that have no body when the
+// client accepts JSON, or body unchanged otherwise.
+func problemFor(r *Request, status int, body interface{}) interface{} {
+	if !problemStatuses[status] || !isBlank(body) || !r.acceptsJson() {
+		return body
+	}
+	problem := NewProblem(status, "")
+	problem.Instance = r.URL.Path
+	return problem
+}
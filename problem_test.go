@@ -0,0 +1,114 @@
+package gadget
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type ProblemSuite struct{}
+
+type problemApp struct {
+	*App
+}
+
+var pa *problemApp
+
+var _ = Suite(&ProblemSuite{})
+
+func (s *ProblemSuite) SetUpSuite(c *C) {
+	pa = &problemApp{&App{}}
+	pa.Register(&ProblemController{})
+	pa.Accept("application/json").Via(JsonBroker)
+	pa.Accept(ProblemMimeType).Via(ProblemBroker)
+	pa.Routes(pa.Resource("problems"))
+}
+
+func (s *ProblemSuite) TearDownSuite(c *C) {
+	pa.Controllers = make(map[string]Controller)
+}
+
+type ProblemController struct {
+	*DefaultController
+}
+
+func (c *ProblemController) Index(r *Request) (int, interface{}) {
+	return 409, NewProblem(409, "Widget already exists").Set("widget_id", 7)
+}
+
+func (c *ProblemController) Explode(r *Request) (int, interface{}) {
+	panic("kaboom")
+}
+
+func (s *ProblemSuite) request(c *C, path, accept string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8000/"+path, nil)
+	c.Assert(err, IsNil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp := httptest.NewRecorder()
+	pa.Handler()(resp, req)
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	var doc map[string]interface{}
+	json.Unmarshal(body, &doc)
+	return resp, doc
+}
+
+//A Problem returned from an action is served as application/problem+json with its extensions flattened
+func (s *ProblemSuite) TestProblemReturnedFromAction(c *C) {
+	resp, doc := s.request(c, "problems", "application/json")
+	c.Assert(resp.Code, Equals, 409)
+	c.Assert(resp.Header().Get("Content-Type"), Equals, ProblemMimeType)
+	c.Assert(doc["title"], Equals, "Conflict")
+	c.Assert(doc["status"], Equals, float64(409))
+	c.Assert(doc["detail"], Equals, "Widget already exists")
+	c.Assert(doc["widget_id"], Equals, float64(7))
+}
+
+//Router 404s become problem documents when the client accepts JSON
+func (s *ProblemSuite) TestRouter404IsProblemForJsonClients(c *C) {
+	resp, doc := s.request(c, "nowhere", "application/json")
+	c.Assert(resp.Code, Equals, 404)
+	c.Assert(resp.Header().Get("Content-Type"), Equals, ProblemMimeType)
+	c.Assert(doc["status"], Equals, float64(404))
+	c.Assert(doc["instance"], Equals, "/nowhere")
+}
+
+//Router 404s are left alone when the client does not accept JSON
+func (s *ProblemSuite) TestRouter404UnchangedForOtherClients(c *C) {
+	resp, _ := s.request(c, "nowhere", "text/html")
+	c.Assert(resp.Code, Equals, 404)
+	c.Assert(resp.Body.String(), Equals, "")
+}
+
+//Verbs that aren't routed for a URL get a 405 problem document with an Allow header
+func (s *ProblemSuite) TestUnroutedVerbIs405Problem(c *C) {
+	req, _ := http.NewRequest("DELETE", "http://127.0.0.1:8000/problems", nil)
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	pa.Handler()(resp, req)
+	c.Assert(resp.Code, Equals, 405)
+	c.Assert(resp.Header().Get("Allow"), Equals, "GET, HEAD, POST, OPTIONS")
+	c.Assert(resp.Header().Get("Content-Type"), Equals, ProblemMimeType)
+	var doc map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &doc)
+	c.Assert(doc["title"], Equals, "Method Not Allowed")
+}
+
+//Recovered panics become problem documents when the client accepts JSON
+func (s *ProblemSuite) TestPanicIsProblemForJsonClients(c *C) {
+	resp, doc := s.request(c, "problems/explode", ProblemMimeType)
+	c.Assert(resp.Code, Equals, 500)
+	c.Assert(resp.Header().Get("Content-Type"), Equals, ProblemMimeType)
+	c.Assert(doc["title"], Equals, "Internal Server Error")
+}
+
+//ProblemBroker wraps non-Problem bodies in a Problem
+func (s *ProblemSuite) TestProblemBrokerWrapsOtherBodies(c *C) {
+	status, body := ProblemBroker(&Request{}, 403, "Verboten", &RouteData{})
+	c.Assert(status, Equals, 403)
+	c.Assert(body, Equals, `{"detail":"Verboten","status":403,"title":"Forbidden"}`)
+}
@@ -30,6 +30,21 @@ func Get(table string) string { return tables[table].get() }
 // Named(Query|ExecStmt) bindvar syntax.
 func Select(table string) string { return tables[table]._select() }
 
+// Pager is the interface satisfied by gadget.Page, describing the slice of
+// rows a paginated query should return.
+type Pager interface {
+	Limit() int
+	Offset() int
+}
+
+// Paginate appends LIMIT and OFFSET clauses taken from a Pager to query q.
+//
+// 	page := gadget.Paginate(r, 20)
+// 	q := query.Paginate(fmt.Sprintf(query.Select("posts"), "ORDER BY id"), page)
+func Paginate(q string, p Pager) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", q, p.Limit(), p.Offset())
+}
+
 // Columns returns a slice of strings listing the names of columns expected
 // given the struct registered with name table.
 func Columns(table string) []string { return tables[table].columns }
@@ -0,0 +1,330 @@
+package gadget
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateStatus describes the outcome of counting a request against a rate
+// limit. Reset is when the client will have its full Limit available again,
+// and RetryAfter, for requests that were not Allowed, is how long the client
+// must wait before its next request would be.
+type RateStatus struct {
+	Allowed          bool
+	Limit, Remaining int
+	Reset            time.Time
+	RetryAfter       time.Duration
+}
+
+// RateAlgorithm is the interface implemented by the strategies a RateLimiter
+// can use to count requests. Take counts a request for key and reports
+// whether it is allowed. Implementations must be safe for concurrent use.
+type RateAlgorithm interface {
+	Take(key string) RateStatus
+}
+
+// TokenBucket is a RateAlgorithm that allows bursts of up to Limit requests
+// and refills at a steady rate of Limit requests per Per.
+type TokenBucket struct {
+	Limit     int
+	Per       time.Duration
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a TokenBucket allowing limit requests per per.
+func NewTokenBucket(limit int, per time.Duration) *TokenBucket {
+	return &TokenBucket{Limit: limit, Per: per, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (tb *TokenBucket) Take(key string) RateStatus {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := time.Now()
+	rate := float64(tb.Limit) / float64(tb.Per)
+	tb.sweep(now)
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(tb.Limit), last: now}
+		tb.buckets[key] = b
+	}
+	b.tokens += float64(now.Sub(b.last)) * rate
+	if b.tokens > float64(tb.Limit) {
+		b.tokens = float64(tb.Limit)
+	}
+	b.last = now
+	status := RateStatus{Limit: tb.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = time.Duration((1 - b.tokens) / rate)
+	}
+	status.Remaining = int(b.tokens)
+	status.Reset = now.Add(time.Duration((float64(tb.Limit) - b.tokens) / rate))
+	return status
+}
+
+// sweep discards buckets that have had time to refill completely, at most
+// once per Per.
+func (tb *TokenBucket) sweep(now time.Time) {
+	if now.Sub(tb.lastSweep) < tb.Per {
+		return
+	}
+	tb.lastSweep = now
+	for key, b := range tb.buckets {
+		if now.Sub(b.last) >= tb.Per {
+			delete(tb.buckets, key)
+		}
+	}
+}
+
+// SlidingWindow is a RateAlgorithm that allows at most Limit requests in any
+// span of Window. It remembers the time of each request within the window, so
+// it uses more memory than a TokenBucket but never allows a burst of more
+// than Limit requests.
+type SlidingWindow struct {
+	Limit     int
+	Window    time.Duration
+	mu        sync.Mutex
+	logs      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// NewSlidingWindow returns a SlidingWindow allowing limit requests per window.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, logs: make(map[string][]time.Time), lastSweep: time.Now()}
+}
+
+func (sw *SlidingWindow) Take(key string) RateStatus {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := time.Now()
+	sw.sweep(now)
+	times := sw.current(key, now)
+	status := RateStatus{Limit: sw.Limit}
+	if len(times) < sw.Limit {
+		times = append(times, now)
+		status.Allowed = true
+	} else {
+		status.RetryAfter = times[0].Add(sw.Window).Sub(now)
+	}
+	sw.logs[key] = times
+	status.Remaining = sw.Limit - len(times)
+	if len(times) > 0 {
+		status.Reset = times[len(times)-1].Add(sw.Window)
+	} else {
+		status.Reset = now
+	}
+	return status
+}
+
+// current returns the times of the requests for key that are still within
+// the window.
+func (sw *SlidingWindow) current(key string, now time.Time) []time.Time {
+	times := sw.logs[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= sw.Window {
+		i++
+	}
+	return times[i:]
+}
+
+// sweep discards the logs of keys with no requests within the window, at most
+// once per Window.
+func (sw *SlidingWindow) sweep(now time.Time) {
+	if now.Sub(sw.lastSweep) < sw.Window {
+		return
+	}
+	sw.lastSweep = now
+	for key := range sw.logs {
+		if len(sw.current(key, now)) == 0 {
+			delete(sw.logs, key)
+		}
+	}
+}
+
+// RateKey identifies the client that a request is counted against.
+type RateKey func(*Request) string
+
+// KeyByIP counts requests against the client's IP address, as returned by
+// Request.ClientIP.
+func KeyByIP(r *Request) string {
+	return "ip:" + r.ClientIP()
+}
+
+// KeyByUser returns a RateKey that counts requests from authenticated users
+// against the id that the id func returns for them, and requests from
+// anonymous users against their IP address.
+//
+// 	gadget.KeyByUser(func(u gadget.User) string {
+// 		return strconv.Itoa(u.(*models.User).Id)
+// 	})
+func KeyByUser(id func(User) string) RateKey {
+	return func(r *Request) string {
+		if user := r.User(); user.Authenticated() {
+			return "user:" + id(user)
+		}
+		return KeyByIP(r)
+	}
+}
+
+// RateLimiter refuses requests from clients that exceed the rate allowed by
+// its RateAlgorithm with a 429 Too Many Requests and a Retry-After header. All
+// responses to counted requests carry X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset headers, the last in seconds since the Unix epoch.
+//
+// A RateLimiter's Filter method can be added to individual actions:
+//
+// 	limiter := gadget.LimitRate(gadget.KeyByIP, gadget.NewTokenBucket(10, time.Minute))
+// 	c.Filter(limiter.Filter, "create")
+//
+// and its Handler method can be installed with App.Use to limit every request
+// to the application.
+type RateLimiter struct {
+	Key       RateKey
+	Algorithm RateAlgorithm
+}
+
+// LimitRate returns a RateLimiter that counts requests by key with algorithm.
+func LimitRate(key RateKey, algorithm RateAlgorithm) *RateLimiter {
+	return &RateLimiter{Key: key, Algorithm: algorithm}
+}
+
+// take counts r and returns the status along with the headers describing it.
+func (l *RateLimiter) take(r *Request) (RateStatus, http.Header) {
+	status := l.Algorithm.Take(l.Key(r))
+	headers := make(http.Header)
+	headers.Set("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+	headers.Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	headers.Set("X-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+	if !status.Allowed {
+		headers.Set("Retry-After", strconv.Itoa(int((status.RetryAfter+time.Second-1)/time.Second)))
+	}
+	return status, headers
+}
+
+// Filter counts the request, responding with a 429 if the client has exceeded
+// the limit.
+func (l *RateLimiter) Filter(r *Request) (int, interface{}) {
+	status, headers := l.take(r)
+	if !status.Allowed {
+		response := NewResponse("")
+		response.Headers = headers
+		return 429, response
+	}
+	r.addHeaders(headers)
+	return 0, nil
+}
+
+// Handler returns an http.Handler that counts every request before passing it
+// on to h. Installed with App.Use, it refuses requests with the same response
+// as Filter, including problem details and CORS headers.
+func (l *RateLimiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, raw *http.Request) {
+		req, raw := withRequest(raw)
+		status, headers := l.take(req)
+		if !status.Allowed && req.app != nil {
+			response := NewResponse("")
+			response.Headers = headers
+			req.app.refuse(w, req, 429, response)
+			return
+		}
+		for name, values := range headers {
+			w.Header()[name] = values
+		}
+		if !status.Allowed {
+			http.Error(w, http.StatusText(429), 429)
+			return
+		}
+		h.ServeHTTP(w, raw)
+	})
+}
+
+var trustedProxies []*net.IPNet
+
+// TrustProxies sets the addresses of the reverse proxies whose
+// X-Forwarded-For headers Request.ClientIP believes. Each argument is an IP
+// address or a CIDR range; TrustProxies panics if one is neither. Peers
+// connecting over a unix domain socket, as a proxy does when the application
+// is served with -socket, are always trusted, since they have no IP address
+// and only local processes can reach the socket.
+//
+// 	gadget.TrustProxies("127.0.0.1", "10.0.0.0/8")
+func TrustProxies(proxies ...string) {
+	trustedProxies = nil
+	for _, proxy := range proxies {
+		cidr := proxy
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid proxy address '%s'", proxy))
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromUnixSocket reports whether r was accepted on a unix domain socket.
+func (r *Request) fromUnixSocket() bool {
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && local.Network() == "unix"
+}
+
+// ClientIP returns the IP address of the client making the request. If the
+// request came from a proxy registered with TrustProxies or over a unix domain
+// socket, the X-Forwarded-For header is read from right to left, and the first
+// address that isn't a trusted proxy is returned.
+func (r *Request) ClientIP() string {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	if !r.fromUnixSocket() && !trustedProxy(addr) {
+		return addr
+	}
+	var forwarded []string
+	for _, header := range r.Request.Header["X-Forwarded-For"] {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return addr
+}
@@ -0,0 +1,193 @@
+package gadget
+
+import (
+	"context"
+	. "launchpad.net/gocheck"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+type RateLimitSuite struct{}
+
+type rateApp struct {
+	*App
+}
+
+var rla *rateApp
+
+var _ = Suite(&RateLimitSuite{})
+
+func (s *RateLimitSuite) SetUpTest(c *C) {
+	rla = &rateApp{&App{}}
+	ctlr := &VoteController{}
+	rla.Register(ctlr)
+	ctlr.Filter(LimitRate(KeyByIP, NewTokenBucket(2, time.Minute)).Filter, "create")
+	ctlr.Filter(LimitRate(KeyByIP, NewTokenBucket(5, time.Minute)).Filter, "update")
+	ctlr.Filter(func(r *Request) (int, interface{}) {
+		return 403, ""
+	}, "update")
+	rla.Accept("application/json").Via(JsonBroker)
+	rla.Routes(rla.Resource("votes"))
+}
+
+func (s *RateLimitSuite) TearDownTest(c *C) {
+	rla.Controllers = make(map[string]Controller)
+	TrustProxies()
+	clearUserIdentifier()
+}
+
+type VoteController struct {
+	*DefaultController
+}
+
+func (c *VoteController) Index(r *Request) (int, interface{}) {
+	return 200, "ok"
+}
+
+func (c *VoteController) Create(r *Request) (int, interface{}) {
+	return 201, "ok"
+}
+
+func (c *VoteController) Update(r *Request) (int, interface{}) {
+	return 200, "ok"
+}
+
+func (s *RateLimitSuite) send(method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://127.0.0.1:8000/"+path, nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	rla.Handler()(resp, req)
+	return resp
+}
+
+//A RateLimiter Filter should add X-RateLimit headers to allowed responses
+func (s *RateLimitSuite) TestRatelimiterFilterAddsHeadersToAllowedResponses(c *C) {
+	resp := s.send("POST", "votes", "192.0.2.1:1234")
+	c.Assert(resp.Code, Equals, 201)
+	c.Assert(resp.Header().Get("X-RateLimit-Limit"), Equals, "2")
+	c.Assert(resp.Header().Get("X-RateLimit-Remaining"), Equals, "1")
+	reset, err := strconv.ParseInt(resp.Header().Get("X-RateLimit-Reset"), 10, 64)
+	c.Assert(err, IsNil)
+	c.Assert(reset >= time.Now().Unix(), Equals, true)
+	c.Assert(resp.Header().Get("Retry-After"), Equals, "")
+}
+
+//A RateLimiter Filter should respond with a 429 and Retry-After once the limit is exceeded
+func (s *RateLimitSuite) TestRatelimiterFilterRespondsWith429OnceLimitExceeded(c *C) {
+	s.send("POST", "votes", "192.0.2.1:1234")
+	s.send("POST", "votes", "192.0.2.1:1234")
+	resp := s.send("POST", "votes", "192.0.2.1:1234")
+	c.Assert(resp.Code, Equals, 429)
+	c.Assert(resp.Body.String(), Matches, `.*"status":429.*`)
+	c.Assert(resp.Header().Get("X-RateLimit-Remaining"), Equals, "0")
+	retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+	c.Assert(err, IsNil)
+	c.Assert(retryAfter > 0 && retryAfter <= 30, Equals, true)
+	resp = s.send("POST", "votes", "192.0.2.2:1234")
+	c.Assert(resp.Code, Equals, 201)
+}
+
+//Filters after a RateLimiter Filter that allows the request should still run
+func (s *RateLimitSuite) TestFiltersAfterAllowingRatelimiterStillRun(c *C) {
+	resp := s.send("PUT", "votes/1", "192.0.2.1:1234")
+	c.Assert(resp.Code, Equals, 403)
+	c.Assert(resp.Header().Get("X-RateLimit-Limit"), Equals, "5")
+}
+
+//A RateLimiter installed with Use should limit every request to the application
+func (s *RateLimitSuite) TestRatelimiterInstalledWithUseLimitsEveryRequest(c *C) {
+	rla.Use(LimitRate(KeyByIP, NewSlidingWindow(1, time.Minute)).Handler)
+	resp := s.send("GET", "votes", "192.0.2.1:1234")
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(resp.Header().Get("X-RateLimit-Remaining"), Equals, "0")
+	resp = s.send("GET", "votes/1", "192.0.2.1:1234")
+	c.Assert(resp.Code, Equals, 429)
+	c.Assert(resp.Header().Get("Retry-After"), Equals, "60")
+}
+
+//A RateLimiter installed with Use should refuse requests like its Filter, with problem details and CORS headers
+func (s *RateLimitSuite) TestRatelimiterInstalledWithUseRefusesLikeFilter(c *C) {
+	rla.Cors(&CorsPolicy{Origins: []string{"https://example.com"}})
+	rla.Use(LimitRate(KeyByIP, NewSlidingWindow(1, time.Minute)).Handler)
+	s.send("GET", "votes", "192.0.2.1:1234")
+	req := httptest.NewRequest("GET", "http://127.0.0.1:8000/votes", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Origin", "https://example.com")
+	resp := httptest.NewRecorder()
+	rla.Handler()(resp, req)
+	c.Assert(resp.Code, Equals, 429)
+	c.Assert(resp.Body.String(), Matches, `.*"status":429.*`)
+	c.Assert(resp.Header().Get("Access-Control-Allow-Origin"), Equals, "https://example.com")
+	c.Assert(resp.Header().Get("Retry-After"), Equals, "60")
+}
+
+//KeyByUser should count authenticated users separately from their IP address
+func (s *RateLimitSuite) TestKeybyuserCountsAuthenticatedUsersSeparately(c *C) {
+	IdentifyUsersWith(func(r *Request) User {
+		if name := r.Request.Header.Get("X-User"); name != "" {
+			return &Identity{Id: name}
+		}
+		return nil
+	})
+	key := KeyByUser(func(u User) string { return u.(*Identity).Id })
+	req := httptest.NewRequest("GET", "/votes", nil)
+	c.Assert(key(newRequest(req)), Equals, "ip:192.0.2.1")
+	req.Header.Set("X-User", "alice")
+	c.Assert(key(newRequest(req)), Equals, "user:alice")
+}
+
+//A SlidingWindow should allow requests again once the window has passed
+func (s *RateLimitSuite) TestSlidingwindowAllowsRequestsAfterWindow(c *C) {
+	sw := NewSlidingWindow(2, 20*time.Millisecond)
+	c.Assert(sw.Take("a").Allowed, Equals, true)
+	c.Assert(sw.Take("a").Allowed, Equals, true)
+	status := sw.Take("a")
+	c.Assert(status.Allowed, Equals, false)
+	c.Assert(status.RetryAfter > 0, Equals, true)
+	time.Sleep(30 * time.Millisecond)
+	status = sw.Take("a")
+	c.Assert(status.Allowed, Equals, true)
+	c.Assert(status.Remaining, Equals, 1)
+}
+
+//A TokenBucket should refill at its steady rate
+func (s *RateLimitSuite) TestTokenbucketRefillsAtSteadyRate(c *C) {
+	tb := NewTokenBucket(1, 20*time.Millisecond)
+	c.Assert(tb.Take("a").Allowed, Equals, true)
+	c.Assert(tb.Take("a").Allowed, Equals, false)
+	time.Sleep(25 * time.Millisecond)
+	c.Assert(tb.Take("a").Allowed, Equals, true)
+}
+
+//ClientIP should only believe X-Forwarded-For from trusted proxies
+func (s *RateLimitSuite) TestClientipOnlyBelievesForwardedForFromTrustedProxies(c *C) {
+	req := httptest.NewRequest("GET", "/votes", nil)
+	req.RemoteAddr = "10.0.0.5:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7, 10.0.0.2")
+	c.Assert(newRequest(req).ClientIP(), Equals, "10.0.0.5")
+	TrustProxies("10.0.0.0/8")
+	c.Assert(newRequest(req).ClientIP(), Equals, "198.51.100.7")
+	TrustProxies("10.0.0.0/8", "198.51.100.7")
+	c.Assert(newRequest(req).ClientIP(), Equals, "203.0.113.9")
+}
+
+//TrustProxies should panic when given something other than an address or range
+func (s *RateLimitSuite) TestTrustproxiesPanicsOnInvalidAddress(c *C) {
+	c.Assert(func() { TrustProxies("localhost") }, PanicMatches, "Invalid proxy address 'localhost'")
+}
+
+//ClientIP should believe X-Forwarded-For from peers on a unix domain socket
+func (s *RateLimitSuite) TestClientipBelievesForwardedForOverUnixSocket(c *C) {
+	req := httptest.NewRequest("GET", "/votes", nil)
+	local := &net.UnixAddr{Name: "/run/gadget.sock", Net: "unix"}
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, local))
+	req.RemoteAddr = "@"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	c.Assert(newRequest(req).ClientIP(), Equals, "203.0.113.9")
+	c.Assert(KeyByIP(newRequest(req)), Equals, "ip:203.0.113.9")
+}
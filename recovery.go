@@ -0,0 +1,105 @@
+package gadget
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redneckbeard/gadget/env"
+)
+
+// PanicReport describes a panic recovered while handling a request. The Id is
+// also sent to the client in the 500 response and in an X-Error-Id header so
+// that a report can be matched with what a user saw.
+type PanicReport struct {
+	Id           string
+	Value        interface{}
+	Stack        string
+	Method, Path string
+	Params       map[string]interface{}
+	UrlParams    map[string]string
+	Time         time.Time
+}
+
+// PanicReporter is a function type that receives a PanicReport for every panic
+// recovered by the Handler.
+type PanicReporter func(*PanicReport)
+
+var reportPanic PanicReporter = LogPanic
+
+// ReportPanicsWith allows Gadget applications to register a PanicReporter to
+// forward recovered panics to an error tracking service or similar. Passing
+// nil restores the default, LogPanic.
+func ReportPanicsWith(pr PanicReporter) {
+	if pr == nil {
+		pr = LogPanic
+	}
+	reportPanic = pr
+}
+
+// LogPanic is the default PanicReporter. It writes the error id, panic value
+// and stack to the application log.
+func LogPanic(p *PanicReport) {
+	env.Log(fmt.Sprintf(`[%s] panic serving "%s %s" (error id %s): %v`, p.Time.Format(time.RFC822), p.Method, p.Path, p.Id, p.Value), "\n"+p.Stack)
+}
+
+func newPanicReport(r *Request, value interface{}, stack []byte) *PanicReport {
+	return &PanicReport{
+		Id:        newErrorId(),
+		Value:     value,
+		Stack:     cleanStack(string(stack)),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Params:    r.Params,
+		UrlParams: r.UrlParams,
+		Time:      time.Now(),
+	}
+}
+
+func newErrorId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// cleanStack removes the frames for the deferred recover and the runtime's
+// panic machinery from a stack trace, leaving the goroutine header followed
+// by the frame that panicked.
+func cleanStack(stack string) string {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic(") && i+2 <= len(lines) {
+			return strings.Join(append(lines[:1], lines[i+2:]...), "\n")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *PanicReport) trace() string {
+	return fmt.Sprintf("panic: %v\nerror id: %s\n\n%s", p.Value, p.Id, p.Stack)
+}
+
+// response builds the 500 sent to the client. Only requests in debug mode see
+// the panic value and stack; everyone else gets the error id.
+func (p *PanicReport) response(r *Request) *Response {
+	var body interface{}
+	switch {
+	case r.acceptsJson():
+		problem := NewProblem(500, "").Set("error_id", p.Id)
+		if r.Debug() {
+			problem.Detail = p.trace()
+		}
+		body = problem
+	case r.Debug():
+		body = p.trace()
+	default:
+		body = fmt.Sprintf("Internal Server Error (error id %s)", p.Id)
+	}
+	response := NewResponse(body)
+	response.Headers.Set("X-Error-Id", p.Id)
+	return response
+}
@@ -0,0 +1,74 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type RecoverySuite struct{}
+
+type recoveryApp struct {
+	*App
+}
+
+var rca *recoveryApp
+
+var _ = Suite(&RecoverySuite{})
+
+func (s *RecoverySuite) SetUpSuite(c *C) {
+	rca = &recoveryApp{&App{}}
+	rca.Register(&PanickyController{})
+	rca.Routes(rca.Resource("panickys"))
+}
+
+func (s *RecoverySuite) TearDownSuite(c *C) {
+	rca.Controllers = make(map[string]Controller)
+}
+
+func (s *RecoverySuite) TearDownTest(c *C) {
+	ReportPanicsWith(nil)
+}
+
+type PanickyController struct {
+	*DefaultController
+}
+
+func (c *PanickyController) Show(r *Request) (int, interface{}) {
+	panic("nobody expects it")
+}
+
+//Recovered panics are passed to the registered PanicReporter with request details and a cleaned stack
+func (s *RecoverySuite) TestPanicReportedWithRequestDetails(c *C) {
+	var report *PanicReport
+	ReportPanicsWith(func(p *PanicReport) { report = p })
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/panickys/12?color=red", nil)
+	resp := httptest.NewRecorder()
+	rca.Handler()(resp, req)
+
+	c.Assert(report, NotNil)
+	c.Assert(report.Value, Equals, "nobody expects it")
+	c.Assert(report.Method, Equals, "GET")
+	c.Assert(report.Path, Equals, "/panickys/12")
+	c.Assert(report.Params["color"], Equals, "red")
+	c.Assert(report.UrlParams["panicky_id"], Equals, "12")
+	c.Assert(report.Id, Not(Equals), "")
+	lines := strings.Split(report.Stack, "\n")
+	c.Assert(lines[0], Matches, `goroutine \d+ \[running\]:`)
+	c.Assert(lines[1], Matches, `.*PanickyController\).Show.*`)
+}
+
+//The error id is included in the 500 response
+func (s *RecoverySuite) TestErrorIdInResponse(c *C) {
+	var report *PanicReport
+	ReportPanicsWith(func(p *PanicReport) { report = p })
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/panickys/12", nil)
+	resp := httptest.NewRecorder()
+	rca.Handler()(resp, req)
+
+	c.Assert(resp.Code, Equals, 500)
+	c.Assert(resp.Header().Get("X-Error-Id"), Equals, report.Id)
+	c.Assert(strings.Contains(resp.Body.String(), report.Id), Equals, true)
+	c.Assert(strings.Contains(resp.Body.String(), "nobody expects it"), Equals, false)
+}
@@ -2,7 +2,6 @@ package gadget
 
 import (
 	"fmt"
-	"github.com/redneckbeard/gadget/env"
 	"github.com/redneckbeard/quimby"
 	"net/http"
 	"os"
@@ -51,10 +50,21 @@ func (a *App) printRoutes() {
 // App provides core Gadget functionality.
 type App struct {
 	routes      []*route
+	middleware  []func(http.Handler) http.Handler
+	cors        *CorsPolicy
 	Brokers     map[string]Broker
 	Controllers map[string]Controller
 }
 
+// Use wraps the Handler for the application in middleware, so that it sees
+// every request before the router does. Middleware passed to earlier calls,
+// and earlier arguments to the same call, run first.
+//
+// 	app.Use(gadget.LimitRate(gadget.KeyByIP, gadget.NewTokenBucket(100, time.Minute)).Handler)
+func (a *App) Use(middleware ...func(http.Handler) http.Handler) {
+	a.middleware = append(a.middleware, middleware...)
+}
+
 // Routes registers a variable number of routes with the Gadget router. Arguments to
 // Routes should be calls to SetIndex, Resource, or Prefixed.
 func (a *App) Routes(rtes ...*route) {
@@ -121,16 +131,35 @@ func (a *App) match(r *Request) (matched *route, status int, body interface{}, a
 		if route.Match(r) != nil {
 			matched = route
 			if matched.controller == nil {
+				r.parseQuery()
 				return matched, 0, nil, ""
 			}
-			status, body, action := route.Respond(r)
+			r.security = route.controller.securityHeaders()
+			a.allowCors(route, r)
+			r.parseBody(route.controller.bodyLimit(route.GetActionName(r)))
+			status, body, action := route.respond(r, a.preconditionFailed)
 			return matched, status, body, action
 		}
 	}
 	return nil, 404, nil, ""
 }
 
-func (a *App) write(w http.ResponseWriter, r *Request, matched *route, status int, body interface{}, action string) {
+// render runs body through the Broker for the request and returns the
+// resulting Response, ready to be written.
+func (a *App) render(r *Request, matched *route, status int, body interface{}, action string) *Response {
+	if cached, ok := body.(*cachedResponse); ok {
+		return cached.response()
+	}
+	response := a.renderUncached(r, matched, status, body, action)
+	if matched != nil {
+		matched.controller.storeCached(r, action, response)
+	}
+	return response
+}
+
+// renderUncached does the work of render without consulting or storing to the
+// action's cache.
+func (a *App) renderUncached(r *Request, matched *route, status int, body interface{}, action string) *Response {
 	var response *Response
 	routeData := &RouteData{
 		Action: action,
@@ -150,37 +179,92 @@ func (a *App) write(w http.ResponseWriter, r *Request, matched *route, status in
 		response = NewResponse(body)
 	}
 
+	response.Body = problemFor(r, status, response.Body)
+	if _, ok := response.Body.(*Problem); ok && r.acceptsJson() {
+		if _, registered := a.Brokers[ProblemMimeType]; registered {
+			contentType = ProblemMimeType
+		}
+	}
+
 	status, final, mime, _ := a.process(r, status, response.Body, contentType, routeData)
 
+	if page, ok := response.Body.(*Page); ok && strings.Contains(mime, "json") {
+		page.setHeaders(response.Headers)
+	}
 	response.status = status
 	response.final = final
 	response.Headers.Set("Content-Type", mime)
-	response.write(w)
-	r.log(status, len(response.final))
+	if matched != nil && matched.controller.etagged(action) {
+		response.setETag(r)
+	}
+	return response
+}
+
+func (a *App) write(w http.ResponseWriter, r *Request, matched *route, status int, body interface{}, action string) {
+	response := a.render(r, matched, status, body, action)
+	response.checkFreshness(r)
+	response.write(w, r)
+	r.log(response.status, len(response.final))
+}
+
+// refuse writes status and body for a request that middleware has turned away
+// before it reached the router, with the CORS and security headers of the
+// route it was bound for and the same problem details as a Filter's response.
+func (a *App) refuse(w http.ResponseWriter, r *Request, status int, body interface{}) {
+	for _, route := range a.routes {
+		if route.Match(r) != nil {
+			if route.controller != nil {
+				r.security = route.controller.securityHeaders()
+				a.allowCors(route, r)
+			}
+			break
+		}
+	}
+	a.write(w, r, nil, status, body, "")
 }
 
 // Handler returns a func encapsulating the Gadget router (and corresponding
-// controllers that can be used in a call to http.HandleFunc. Handler must be
-// invoked only after Routes has been called and all Controllers have been
-// registered.
+// controllers), wrapped in any middleware passed to Use, that can be used in a
+// call to http.HandleFunc. Handler must be invoked only after Routes has been
+// called and all Controllers have been registered. The *Request is created
+// before any middleware runs, so that RequestFor and UserFor in middleware see
+// the same *Request, and the same User, as the router and its actions.
 //
 // In theory, Gadget users will not ever have to call Handler, as Gadget will
 // set up http.HandleFunc to use its return value.
 func (a *App) Handler() http.HandlerFunc {
+	var handler http.Handler = a.router()
+	for i := len(a.middleware) - 1; i >= 0; i-- {
+		handler = a.middleware[i](handler)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, r := withRequest(r)
+		req.app = a
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// redirectStatuses are the statuses for which the router treats an action's
+// body as the URL to redirect to.
+var redirectStatuses = map[int]bool{301: true, 302: true, 303: true, 307: true, 308: true}
+
+func (a *App) router() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var final string
-		req := newRequest(r)
+		req, r := withRequest(r)
+		if req.Method == "OPTIONS" {
+			if resp := a.options(req); resp != nil {
+				resp.write(w, req)
+				req.log(resp.status, 0)
+				return
+			}
+		}
+		defer req.removeTempFiles()
 		defer func() {
 			if r := recover(); r != nil {
-				trace := string(debug.Stack())
-				lines := strings.Split(trace, "\n")
-				trace = strings.Join(lines[6:], "\n")
-				if env.Debug {
-					a.write(w, req, nil, 500, trace, "")
-				} else {
-					a.write(w, req, nil, 500, nil, "")
-					env.Log(trace)
-				}
+				report := newPanicReport(req, r, debug.Stack())
+				reportPanic(report)
+				a.write(w, req, nil, 500, report.response(req), "")
 			}
 		}()
 		matched, status, body, action := a.match(req)
@@ -188,7 +272,7 @@ func (a *App) Handler() http.HandlerFunc {
 			matched.handler(w, r)
 			return
 		}
-		if status == 301 || status == 302 {
+		if redirectStatuses[status] {
 			resp, ok := body.(*Response)
 			if ok {
 				final = resp.Body.(string)
@@ -198,7 +282,7 @@ func (a *App) Handler() http.HandlerFunc {
 			}
 			resp.Headers.Set("Location", final)
 			resp.status = status
-			resp.write(w)
+			resp.write(w, req)
 			req.log(status, len(final))
 			return
 		}
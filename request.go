@@ -1,11 +1,13 @@
 package gadget
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/redneckbeard/gadget/env"
-	"io/ioutil"
+	"github.com/redneckbeard/gadget/session"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
@@ -27,22 +29,34 @@ var (
 // Request wraps an *http.Request and adds some Gadget-derived conveniences. The
 // Params map contains either POST data, GET query parameters, or the body of the
 // request deserialized as JSON if the request sends an Accept header of
-// application/json. The UrlParams map contains any resource ids plucked from the
-// URL by the router. The User is either an AnonymousUser or an object returned by
-// the UserIdentifier that the application as registered with IdentifyUsersWith.
+// application/json. Bracketed keys in POST data and query parameters, like
+// user[address][city], are parsed into nested maps and slices, and files
+// uploaded in multipart/form-data bodies are found in Files. The UrlParams map
+// contains any resource ids plucked from the URL by the router. The User
+// method returns either an AnonymousUser or an object returned by the
+// UserIdentifier that the application as registered with IdentifyUsersWith.
 type Request struct {
 	*http.Request
 	Params    map[string]interface{}
 	Path      string
 	UrlParams map[string]string
-	User      User
+	Files     Files
 	RawJson   []byte
+	raw       []byte
+	parsed    bool
+	parseErr  *ParseError
+	session   *session.Session
+	flashIn   []Flash
+	flashOut  []Flash
+	user      User
+	headers   http.Header
+	security  *SecurityHeaders
+	nonce     string
+	app       *App
 }
 
 func newRequest(raw *http.Request) *Request {
-	r := &Request{Request: raw, Path: raw.URL.Path[1:]}
-	r.setParams()
-	return r
+	return &Request{Request: raw, Path: raw.URL.Path[1:]}
 }
 
 // ContentType is sort of a dishonest method -- it returns the value of an
@@ -55,6 +69,10 @@ func (r *Request) ContentType() string {
 	return r.contentType()
 }
 
+func (r *Request) acceptsJson() bool {
+	return strings.Contains(r.ContentType(), "json")
+}
+
 // Debug returns true if env.Debug is true or if SetDebugWith returns true when
 // passed its receiver r.
 func (r *Request) Debug() bool {
@@ -65,60 +83,167 @@ func (r *Request) Unmarshal(i interface{}) error {
 	return json.Unmarshal(r.RawJson, i)
 }
 
+// unpackValues copies form values into params. Keys without brackets map to a
+// string, or to a []string if they were given more than once. Bracketed keys
+// are parsed into nested maps and slices the way JSON bodies are:
+// user[address][city]=x sets params["user"] to a map containing an "address"
+// map, items[]=1&items[]=2 sets params["items"] to a []interface{}, and
+// items[][name]=a&items[][name]=b builds a slice of maps, pairing values by
+// position. Keys that conflict with an earlier key of a different shape are
+// dropped.
 func unpackValues(params map[string]interface{}, values map[string][]string) {
-	for k, v := range values {
-		if len(v) == 1 {
-			params[k] = v[0]
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		segments := splitParamKey(k)
+		if len(segments) == 1 {
+			params[k] = paramValue(values[k])
 		} else {
-			params[k] = v
+			insertParam(params, segments, values[k])
 		}
 	}
 }
 
-func (r *Request) contentType() string {
-	return strings.Split(r.Request.Header.Get("Content-Type"), ";")[0]
+// splitParamKey splits "user[address][city]" into "user", "address" and
+// "city". Keys that aren't well-formed are returned whole.
+func splitParamKey(key string) []string {
+	open := strings.Index(key, "[")
+	if open <= 0 || !strings.HasSuffix(key, "]") {
+		return []string{key}
+	}
+	segments := []string{key[:open]}
+	for _, segment := range strings.Split(key[open+1:len(key)-1], "][") {
+		if strings.ContainsAny(segment, "[]") {
+			return []string{key}
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+func paramValue(v []string) interface{} {
+	if len(v) == 1 {
+		return v[0]
+	}
+	return v
 }
 
-func (r *Request) setParams() {
-	params := make(map[string]interface{})
-	switch ct := r.contentType(); {
-	case ct == "application/json":
-		if r.Body != nil {
-			raw, err := ioutil.ReadAll(r.Body)
-			defer r.Body.Close()
-			if err != nil {
+func insertParam(params map[string]interface{}, segments, values []string) {
+	head, rest := segments[0], segments[1:]
+	switch {
+	case len(rest) == 0:
+		if _, exists := params[head]; !exists {
+			params[head] = paramValue(values)
+		}
+	case rest[0] != "":
+		nested, ok := params[head].(map[string]interface{})
+		if !ok {
+			if _, exists := params[head]; exists {
 				return
 			}
-			err = json.Unmarshal(raw, &params)
-			r.RawJson = raw
-			if err != nil {
-				env.Log("Unable to deserialize JSON payload: ", err, string(raw))
+			nested = make(map[string]interface{})
+			params[head] = nested
+		}
+		insertParam(nested, rest, values)
+	default:
+		list, ok := params[head].([]interface{})
+		if !ok {
+			if _, exists := params[head]; exists {
 				return
 			}
 		}
-	case strings.HasPrefix(ct, "multipart/form-data"):
-		err := r.ParseMultipartForm(10 * 1024 * 1024)
-		if err != nil {
-			return
+		if len(rest) == 1 {
+			for _, v := range values {
+				list = append(list, v)
+			}
+		} else {
+			for i, v := range values {
+				if i == len(list) {
+					list = append(list, make(map[string]interface{}))
+				}
+				if elem, ok := list[i].(map[string]interface{}); ok {
+					insertParam(elem, rest[1:], []string{v})
+				}
+			}
 		}
-		unpackValues(params, r.MultipartForm.Value)
-	default:
-		err := r.ParseForm()
-		if err != nil {
-			return
+		params[head] = list
+	}
+}
+
+func (r *Request) contentType() string {
+	return strings.Split(r.Request.Header.Get("Content-Type"), ";")[0]
+}
+
+// User returns the User making the request. The UserIdentifier registered
+// with IdentifyUsersWith is called the first time User is called for a
+// request, so actions that never ask for the user never pay for identifying
+// them; the result is kept for the rest of the request. If there is no
+// UserIdentifier or it returns nil, User returns an AnonymousUser.
+func (r *Request) User() User {
+	if r.user == nil {
+		if identifyUser != nil {
+			r.user = identifyUser(r)
+		}
+		if r.user == nil {
+			r.user = &AnonymousUser{}
 		}
-		unpackValues(params, r.Form)
 	}
-	r.Params = params
+	return r.user
+}
+
+// SetUser replaces the User for the rest of the request, as after logging a
+// user in or out.
+func (r *Request) SetUser(user User) {
+	r.user = user
 }
 
-func (r *Request) setUser() error {
-	if identifyUser != nil {
-		r.User = identifyUser(r)
-	} else {
-		r.User = &AnonymousUser{}
+// addHeaders records headers to be sent with the response to the request,
+// whatever the action returns. Headers set on the Response take precedence.
+func (r *Request) addHeaders(headers http.Header) {
+	if r.headers == nil {
+		r.headers = make(http.Header)
 	}
-	return nil
+	for name, values := range headers {
+		r.headers[name] = values
+	}
+}
+
+type contextKey int
+
+const requestKey contextKey = 0
+
+// RequestFor returns the *Request that Gadget created for r, so that
+// http.HandlerFuncs mounted with HandleFunc can use its conveniences, such as
+// User. For requests that didn't pass through the Gadget router it returns a
+// new *Request. Bodies of requests to HandleFunc routes are not parsed, so the
+// handler is free to read them itself; Params holds only the query parameters.
+func RequestFor(r *http.Request) *Request {
+	if req, ok := r.Context().Value(requestKey).(*Request); ok {
+		return req
+	}
+	req := newRequest(r)
+	req.parseQuery()
+	return req
+}
+
+// withRequest returns the *Request for r along with a copy of r whose context
+// carries it, so that handlers further down the chain find the same *Request.
+func withRequest(r *http.Request) (*Request, *http.Request) {
+	if req, ok := r.Context().Value(requestKey).(*Request); ok {
+		return req, r
+	}
+	req := newRequest(r)
+	req.Request = r.WithContext(context.WithValue(r.Context(), requestKey, req))
+	return req, req.Request
+}
+
+// UserFor returns the User making the request r, identified the same way as
+// for controller actions. It is shorthand for RequestFor(r).User().
+func UserFor(r *http.Request) User {
+	return RequestFor(r).User()
 }
 
 func (r *Request) log(status, contentLength int) {
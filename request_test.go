@@ -0,0 +1,56 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+type RequestSuite struct{}
+
+var _ = Suite(&RequestSuite{})
+
+//Keys without brackets are unpacked as strings, or string slices when repeated
+func (s *RequestSuite) TestFlatParams(c *C) {
+	r := bindRequest("GET", "http://127.0.0.1:8000/posts?q=go&tag=a&tag=b", "", "")
+	c.Assert(r.Params["q"], Equals, "go")
+	c.Assert(r.Params["tag"], DeepEquals, []string{"a", "b"})
+}
+
+//Bracketed keys are parsed into nested maps
+func (s *RequestSuite) TestNestedParams(c *C) {
+	r := bindRequest("POST", "http://127.0.0.1:8000/users", "application/x-www-form-urlencoded", "user[name]=Jo&user[address][city]=Austin&user[address][zip]=78701")
+	c.Assert(r.Params, DeepEquals, map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "Jo",
+			"address": map[string]interface{}{
+				"city": "Austin",
+				"zip":  "78701",
+			},
+		},
+	})
+}
+
+//Empty brackets build slices, and slices of maps are paired by position
+func (s *RequestSuite) TestSliceParams(c *C) {
+	r := bindRequest("GET", "http://127.0.0.1:8000/orders?ids[]=1&ids[]=2&items[][name]=pen&items[][qty]=2&items[][name]=ink&items[][qty]=1&one[]=x", "", "")
+	c.Assert(r.Params["ids"], DeepEquals, []interface{}{"1", "2"})
+	c.Assert(r.Params["one"], DeepEquals, []interface{}{"x"})
+	c.Assert(r.Params["items"], DeepEquals, []interface{}{
+		map[string]interface{}{"name": "pen", "qty": "2"},
+		map[string]interface{}{"name": "ink", "qty": "1"},
+	})
+}
+
+//Conflicting keys don't clobber earlier values, and malformed keys are left alone
+func (s *RequestSuite) TestConflictingAndMalformedParams(c *C) {
+	r := bindRequest("GET", "http://127.0.0.1:8000/posts?a=1&a[b]=2&c]=3&d[e=4", "", "")
+	c.Assert(r.Params["a"], Equals, "1")
+	c.Assert(r.Params["c]"], Equals, "3")
+	c.Assert(r.Params["d[e"], Equals, "4")
+}
+
+//Multipart forms are parsed the same way
+func (s *RequestSuite) TestMultipartNestedParams(c *C) {
+	body := "--XX\r\nContent-Disposition: form-data; name=\"post[title]\"\r\n\r\nHi\r\n--XX--\r\n"
+	r := bindRequest("POST", "http://127.0.0.1:8000/posts", "multipart/form-data; boundary=XX", body)
+	c.Assert(r.Params["post"], DeepEquals, map[string]interface{}{"title": "Hi"})
+}
@@ -2,18 +2,22 @@ package gadget
 
 import (
 	"fmt"
+	"github.com/redneckbeard/gadget/compress"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Response provides a wrapper around the interface{} value you would normally
 // return for the response body in a Controller method, but gives you the
 // ability to write headers and cookies to accompany the response.
 type Response struct {
-	status  int
-	Body    interface{}
-	final   string
-	Cookies []*http.Cookie
-	Headers http.Header
+	status       int
+	Body         interface{}
+	final        string
+	Cookies      []*http.Cookie
+	Headers      http.Header
+	lastModified time.Time
 }
 
 // NewResponse returns a pointer to a Response with its Body and Headers values
@@ -25,16 +29,31 @@ func NewResponse(body interface{}) *Response {
 	}
 }
 
-func (r *Response) write(w http.ResponseWriter) {
-	h := w.Header()
+func (r *Response) write(w http.ResponseWriter, req *Request) {
+	req.saveFlashes(r)
+	req.saveSession(r)
+	cw := compress.NewResponseWriter(w, req.Request)
+	defer cw.Close()
+	h := cw.Header()
+	security := req.security
+	if security == nil {
+		security = DefaultSecurityHeaders
+	}
+	security.set(h, req)
+	for name, values := range req.headers {
+		h[name] = values
+	}
 	for name, values := range r.Headers {
 		h[name] = values
 	}
 	for _, c := range r.Cookies {
-		http.SetCookie(w, c)
+		http.SetCookie(cw, c)
+	}
+	if r.final != "" {
+		h.Set("Content-Length", strconv.Itoa(len(r.final)))
 	}
-	w.WriteHeader(r.status)
-	fmt.Fprint(w, r.final)
+	cw.WriteHeader(r.status)
+	fmt.Fprint(cw, r.final)
 }
 
 // AddCookie adds a cookie to the Response.
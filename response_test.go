@@ -1,10 +1,13 @@
 package gadget
 
 import (
+	"compress/gzip"
 	"io/ioutil"
 	. "launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -59,10 +62,19 @@ func (c *ResponseController) CookieAndRedirect(*Request) (int, interface{}) {
 	return 302, response
 }
 
+func (c *ResponseController) Big(*Request) (int, interface{}) {
+	return 200, strings.Repeat("gadget ", 500)
+}
+
 func (c *ResponseController) RedirectWithString(*Request) (int, interface{}) {
 	return 301, "/somewhere"
 }
 
+func (c *ResponseController) RedirectWithStatus(r *Request) (int, interface{}) {
+	status, _ := strconv.Atoi(r.Params["status"].(string))
+	return status, "/somewhere"
+}
+
 type ImplicitController struct {
 	*DefaultController
 }
@@ -159,3 +171,57 @@ func (s *ResponseSuite) TestRedirectWithString(c *C) {
 	c.Assert(resp.Code, Equals, 301)
 	c.Assert(resp.Header().Get("Location"), Equals, "/somewhere")
 }
+
+//303, 307 and 308 responses should redirect like 301 and 302
+func (s *ResponseSuite) TestOtherRedirectStatuses(c *C) {
+	for _, status := range []string{"303", "307", "308"} {
+		req, err := http.NewRequest("GET", "http://127.0.0.1:8000/responses/redirect-with-status?status="+status, nil)
+		c.Assert(err, IsNil)
+		resp := httptest.NewRecorder()
+		ra.Handler()(resp, req)
+		c.Assert(strconv.Itoa(resp.Code), Equals, status)
+		c.Assert(resp.Header().Get("Location"), Equals, "/somewhere")
+	}
+}
+
+//Additional brokers registered with Accept and Via are used for their MIME types
+func (s *ResponseSuite) TestAdditionalBrokers(c *C) {
+	ra.Accept("text/csv").Via(CsvBroker)
+	ra.Accept("application/x-yaml").Via(YamlBroker)
+	defer delete(ra.Brokers, "text/csv")
+	defer delete(ra.Brokers, "application/x-yaml")
+	handler := ra.Handler()
+
+	expected := map[string]string{
+		"text/csv":           "Foo,Bar\nbaz,quux\n",
+		"application/x-yaml": "foo: baz\nbar: quux\n",
+	}
+	for mime, body := range expected {
+		req, err := http.NewRequest("GET", "http://127.0.0.1:8000/implicits", nil)
+		c.Assert(err, IsNil)
+		req.Header.Set("Accept", mime)
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		c.Assert(resp.Header().Get("Content-Type"), Equals, mime)
+		c.Assert(resp.Body.String(), Equals, body)
+	}
+}
+
+//Large responses are gzipped when the client accepts it
+func (s *ResponseSuite) TestCompressedResponse(c *C) {
+	handler := ra.Handler()
+
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8000/responses/big", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+
+	c.Assert(resp.Header().Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(resp.Header().Get("Vary"), Equals, "Accept-Encoding")
+	reader, err := gzip.NewReader(resp.Body)
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, strings.Repeat("gadget ", 500))
+}
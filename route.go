@@ -82,6 +82,11 @@ func (rte *route) buildPatterns(prefix string, segments ...*segment) {
 			idPattern: rte.controller.IdPattern(),
 			actions:   rte.controller.extraActionNames(),
 		})
+	} else if rte.handler != nil {
+		rte.segments = append(segments, &segment{
+			name:     rte.segment,
+			isPrefix: true,
+		})
 	} else {
 		rte.segments = append(segments, &segment{
 			name:     prefix,
@@ -98,6 +103,8 @@ func (rte *route) buildPatterns(prefix string, segments ...*segment) {
 		if len(rte.controller.extraActionNames()) > 0 {
 			rte.actionPattern = patterns.actionPattern()
 		}
+	} else if rte.handler != nil {
+		rte.indexPattern = patterns.indexPattern()
 	}
 }
 
@@ -163,11 +170,11 @@ func (rte *route) GetActionName(r *Request) (action string) {
 	case rte.actionPattern != nil && rte.actionPattern.MatchString(r.Path):
 		segments := strings.Split(r.Path, "/")
 		action = segments[len(segments)-1]
-	case atIndex && r.Method == "GET":
+	case atIndex && (r.Method == "GET" || r.Method == "HEAD"):
 		action = "index"
 	case atIndex && r.Method == "POST":
 		action = "create"
-	case !atIndex && r.Method == "GET":
+	case !atIndex && (r.Method == "GET" || r.Method == "HEAD"):
 		action = "show"
 	case !atIndex && (r.Method == "PUT" || r.Method == "PATCH"):
 		action = "update"
@@ -178,16 +185,48 @@ func (rte *route) GetActionName(r *Request) (action string) {
 }
 
 func (rte *route) Respond(r *Request) (status int, body interface{}, action string) {
+	return rte.respond(r, nil)
+}
+
+// respond runs the action for r once the request has passed CSRF verification,
+// authorization and the action's Filters. If precondition is given, it is
+// checked after those, and a request that fails it gets a 412.
+func (rte *route) respond(r *Request, precondition func(*route, *Request) bool) (status int, body interface{}, action string) {
 	action = rte.GetActionName(r)
 	if action == "" {
-		return 404, "", ""
+		return 405, rte.methodNotAllowed(r), ""
+	}
+	r.parseBody(rte.controller.bodyLimit(action))
+	if r.parseErr != nil {
+		if status, body = rte.controller.parseErrorFilter(action)(r); status != 0 {
+			return
+		}
 	}
 	r.UrlParams = rte.GetParams(r)
-	r.setUser()
+	if CsrfProtection && !rte.controller.csrfExempt(action) {
+		if status, body = verifyCsrf(r); status != 0 {
+			return
+		}
+	}
+	if status, body = rte.controller.authorize(r, action); status != 0 {
+		return
+	}
 	status, body = rte.controller.runFilters(r, action)
 	if status != 0 {
 		return
 	}
+	if precondition != nil && precondition(rte, r) {
+		return 412, "", action
+	}
+	if cached := rte.controller.cached(r, action); cached != nil {
+		return cached.Status, cached, action
+	}
+	status, body = rte.call(r, action)
+	return
+}
+
+// call invokes the controller method for action.
+func (rte *route) call(r *Request, action string) (int, interface{}) {
 	var methodName string
 	if extra, ok := rte.controller.extraActions()[action]; ok {
 		methodName = extra
@@ -198,7 +237,5 @@ func (rte *route) Respond(r *Request) (status int, body interface{}, action stri
 	method, _ := t.MethodByName(methodName)
 	arguments := []reflect.Value{reflect.ValueOf(rte.controller), reflect.ValueOf(r)}
 	statusAndBody := method.Func.Call(arguments)
-	status = int(statusAndBody[0].Int())
-	body = statusAndBody[1].Interface()
-	return
+	return int(statusAndBody[0].Int()), statusAndBody[1].Interface()
 }
@@ -76,14 +76,14 @@ func (s *RouteSuite) TestRouterespondCallsControllersShowMethodOnGetRequestThatM
 	c.Assert(action, Equals, "show")
 }
 
-//Route.Respond 404s on a POST request that matches its controller's objectPattern
-func (s *RouteSuite) TestRouterespond404SOnPostRequestThatMatchesItsControllersIndexpattern(c *C) {
+//Route.Respond 405s on a POST request that matches its controller's objectPattern
+func (s *RouteSuite) TestRouterespond405SOnPostRequestThatMatchesItsControllersObjectpattern(c *C) {
 	r := rta.newRoute("tell-method-names", nil)
 	r.buildPatterns("")
 	req, _ := http.NewRequest("POST", "http://127.0.0.1:8000/tell-method-names/1", nil)
 	status, body, action := r.Respond(newRequest(req))
-	c.Assert(status, Equals, 404)
-	c.Assert(body.(string), Equals, "")
+	c.Assert(status, Equals, 405)
+	c.Assert(body.(*Response).Headers.Get("Allow"), Equals, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS")
 	c.Assert(action, Equals, "")
 }
 
@@ -98,14 +98,14 @@ func (s *RouteSuite) TestRouterespondCallsControllersCreateMethodOnPostRequestTh
 	c.Assert(action, Equals, "create")
 }
 
-//Route.Respond 404s on a PUT request that matches its controller's indexPattern
-func (s *RouteSuite) TestRouterespond404SOnPutRequestThatMatchesItsControllersIndexpattern(c *C) {
+//Route.Respond 405s on a PUT request that matches its controller's indexPattern
+func (s *RouteSuite) TestRouterespond405SOnPutRequestThatMatchesItsControllersIndexpattern(c *C) {
 	r := rta.newRoute("tell-method-names", nil)
 	r.buildPatterns("")
 	req, _ := http.NewRequest("PUT", "http://127.0.0.1:8000/tell-method-names", nil)
 	status, body, action := r.Respond(newRequest(req))
-	c.Assert(status, Equals, 404)
-	c.Assert(body.(string), Equals, "")
+	c.Assert(status, Equals, 405)
+	c.Assert(body.(*Response).Headers.Get("Allow"), Equals, "GET, HEAD, POST, OPTIONS")
 	c.Assert(action, Equals, "")
 }
 
@@ -131,14 +131,14 @@ func (s *RouteSuite) TestRouterespondCallsControllersUpdateMethodOnPatchRequestT
 	c.Assert(action, Equals, "update")
 }
 
-//Route.Respond 404s on a DELETE request that matches its controller's indexPattern
-func (s *RouteSuite) TestRouterespond404SOnDeleteRequestThatMatchesItsControllersIndexpattern(c *C) {
+//Route.Respond 405s on a DELETE request that matches its controller's indexPattern
+func (s *RouteSuite) TestRouterespond405SOnDeleteRequestThatMatchesItsControllersIndexpattern(c *C) {
 	r := rta.newRoute("tell-method-names", nil)
 	r.buildPatterns("")
 	req, _ := http.NewRequest("DELETE", "http://127.0.0.1:8000/tell-method-names", nil)
 	status, body, action := r.Respond(newRequest(req))
-	c.Assert(status, Equals, 404)
-	c.Assert(body.(string), Equals, "")
+	c.Assert(status, Equals, 405)
+	c.Assert(body.(*Response).Headers.Get("Allow"), Equals, "GET, HEAD, POST, OPTIONS")
 	c.Assert(action, Equals, "")
 }
 
@@ -0,0 +1,90 @@
+package gadget
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// SecurityHeaders holds the values of the security-related headers that Gadget
+// adds to every response. Empty fields are left out. Any occurrence of
+// "{nonce}" in ContentSecurityPolicy is replaced with the request's CspNonce,
+// so that inline scripts and styles rendered with the nonce are allowed:
+//
+// 	gadget.DefaultSecurityHeaders.ContentSecurityPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'"
+//
+// Headers set by the action on its Response take precedence over these.
+type SecurityHeaders struct {
+	ContentSecurityPolicy   string
+	StrictTransportSecurity string
+	FrameOptions            string
+	ContentTypeOptions      string
+	ReferrerPolicy          string
+}
+
+// DefaultSecurityHeaders are the SecurityHeaders sent with responses from
+// controllers that haven't set their own with UseSecurityHeaders, and with
+// responses that aren't routed to a controller. HSTS and CSP are off by
+// default, since they depend on how the application is deployed and what its
+// pages load.
+var DefaultSecurityHeaders = &SecurityHeaders{
+	FrameOptions:       "SAMEORIGIN",
+	ContentTypeOptions: "nosniff",
+	ReferrerPolicy:     "strict-origin-when-cross-origin",
+}
+
+// UseSecurityHeaders sets the SecurityHeaders for this controller's responses,
+// overriding DefaultSecurityHeaders.
+//
+// 	c := &EmbedController{}
+// 	gadget.Register(c)
+// 	c.UseSecurityHeaders(&gadget.SecurityHeaders{ContentTypeOptions: "nosniff"})
+func (c *DefaultController) UseSecurityHeaders(headers *SecurityHeaders) {
+	if c.filters == nil {
+		panic("Calls to UseSecurityHeaders must be made after a controller is registered")
+	}
+	c.security = headers
+}
+
+func (c *DefaultController) securityHeaders() *SecurityHeaders {
+	return c.security
+}
+
+// set adds the headers to h, filling in the CSP nonce for r.
+func (s *SecurityHeaders) set(h http.Header, r *Request) {
+	if s.ContentSecurityPolicy != "" {
+		csp := s.ContentSecurityPolicy
+		if strings.Contains(csp, "{nonce}") {
+			csp = strings.Replace(csp, "{nonce}", r.CspNonce(), -1)
+		}
+		h.Set("Content-Security-Policy", csp)
+	}
+	if s.StrictTransportSecurity != "" {
+		h.Set("Strict-Transport-Security", s.StrictTransportSecurity)
+	}
+	if s.FrameOptions != "" {
+		h.Set("X-Frame-Options", s.FrameOptions)
+	}
+	if s.ContentTypeOptions != "" {
+		h.Set("X-Content-Type-Options", s.ContentTypeOptions)
+	}
+	if s.ReferrerPolicy != "" {
+		h.Set("Referrer-Policy", s.ReferrerPolicy)
+	}
+}
+
+// CspNonce returns a random value, unique to the request, for the nonce
+// attribute of inline <script> and <style> elements. The same value is
+// substituted for "{nonce}" in the Content-Security-Policy header, so responses
+// that use the nonce are never stored by Cache.
+func (r *Request) CspNonce() string {
+	if r.nonce == "" {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			panic(err)
+		}
+		r.nonce = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return r.nonce
+}
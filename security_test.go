@@ -0,0 +1,108 @@
+package gadget
+
+import (
+	. "launchpad.net/gocheck"
+	"net/http/httptest"
+	"strings"
+)
+
+type SecuritySuite struct {
+	defaults *SecurityHeaders
+}
+
+type securityApp struct {
+	*App
+}
+
+var sha *securityApp
+
+var _ = Suite(&SecuritySuite{})
+
+func (s *SecuritySuite) SetUpTest(c *C) {
+	defaults := *DefaultSecurityHeaders
+	s.defaults = &defaults
+	sha = &securityApp{&App{}}
+	sha.Register(&PageController{}, &EmbedController{})
+	sha.Controllers["embeds"].UseSecurityHeaders(&SecurityHeaders{ContentTypeOptions: "nosniff"})
+	sha.Routes(sha.Resource("pages"), sha.Resource("embeds"))
+}
+
+func (s *SecuritySuite) TearDownTest(c *C) {
+	DefaultSecurityHeaders = s.defaults
+	sha.Controllers = make(map[string]Controller)
+}
+
+type PageController struct {
+	*DefaultController
+}
+
+func (c *PageController) Index(r *Request) (int, interface{}) {
+	return 200, `<script nonce="` + r.CspNonce() + `"></script>`
+}
+
+func (c *PageController) Show(r *Request) (int, interface{}) {
+	response := NewResponse("framed")
+	response.Headers.Set("X-Frame-Options", "DENY")
+	return 200, response
+}
+
+type EmbedController struct {
+	*DefaultController
+}
+
+func (c *EmbedController) Index(r *Request) (int, interface{}) {
+	return 200, "embeddable"
+}
+
+func (s *SecuritySuite) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "http://127.0.0.1:8000/"+path, nil)
+	resp := httptest.NewRecorder()
+	sha.Handler()(resp, req)
+	return resp
+}
+
+//Responses should carry the DefaultSecurityHeaders
+func (s *SecuritySuite) TestResponsesCarryDefaultsecurityheaders(c *C) {
+	for _, path := range []string{"pages", "nothing-here"} {
+		h := s.get(path).Header()
+		c.Assert(h.Get("X-Frame-Options"), Equals, "SAMEORIGIN")
+		c.Assert(h.Get("X-Content-Type-Options"), Equals, "nosniff")
+		c.Assert(h.Get("Referrer-Policy"), Equals, "strict-origin-when-cross-origin")
+		c.Assert(h.Get("Strict-Transport-Security"), Equals, "")
+		c.Assert(h.Get("Content-Security-Policy"), Equals, "")
+	}
+}
+
+//The Content-Security-Policy should carry the same nonce as the page
+func (s *SecuritySuite) TestContentsecuritypolicyCarriesSameNonceAsPage(c *C) {
+	DefaultSecurityHeaders.ContentSecurityPolicy = "script-src 'nonce-{nonce}'"
+	DefaultSecurityHeaders.StrictTransportSecurity = "max-age=31536000"
+	resp := s.get("pages")
+	csp := resp.Header().Get("Content-Security-Policy")
+	c.Assert(csp, Matches, `script-src 'nonce-[\w-]{22}'`)
+	nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "script-src 'nonce-"), "'")
+	c.Assert(resp.Body.String(), Equals, `<script nonce="`+nonce+`"></script>`)
+	c.Assert(resp.Header().Get("Strict-Transport-Security"), Equals, "max-age=31536000")
+	c.Assert(s.get("pages").Header().Get("Content-Security-Policy"), Not(Equals), csp)
+}
+
+//Headers set on the Response should take precedence over the SecurityHeaders
+func (s *SecuritySuite) TestResponseHeadersTakePrecedenceOverSecurityheaders(c *C) {
+	resp := s.get("pages/1")
+	c.Assert(resp.Header().Get("X-Frame-Options"), Equals, "DENY")
+	c.Assert(resp.Header().Get("X-Content-Type-Options"), Equals, "nosniff")
+}
+
+//A controller's SecurityHeaders should replace the defaults
+func (s *SecuritySuite) TestControllersSecurityheadersReplaceDefaults(c *C) {
+	h := s.get("embeds").Header()
+	c.Assert(h.Get("X-Content-Type-Options"), Equals, "nosniff")
+	c.Assert(h.Get("X-Frame-Options"), Equals, "")
+	c.Assert(h.Get("Referrer-Policy"), Equals, "")
+}
+
+//Calling UseSecurityHeaders before a controller is registered should panic
+func (s *SecuritySuite) TestCallingUsesecurityheadersBeforeRegisteringPanics(c *C) {
+	ctlr := &EmbedController{&DefaultController{}}
+	c.Assert(func() { ctlr.UseSecurityHeaders(nil) }, PanicMatches, "Calls to UseSecurityHeaders must be made after a controller is registered")
+}
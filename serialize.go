@@ -1,26 +1,161 @@
 package gadget
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"github.com/redneckbeard/gadget/env"
+	"github.com/redneckbeard/gadget/strutil"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"regexp"
+	"strings"
 )
 
+var jsonpCallback = regexp.MustCompile(`^[\w$.]+$`)
+
+// KeyCase determines how a Broker created by NewJsonBroker rewrites the keys of
+// JSON objects.
+type KeyCase int
+
+const (
+	// KeepCase leaves keys as encoding/json produces them.
+	KeepCase KeyCase = iota
+	// SnakeCase converts keys such as "CreatedAt" to "created_at".
+	SnakeCase
+	// CamelCase converts keys such as "CreatedAt" or "created_at" to "createdAt".
+	CamelCase
+)
+
+// JsonOptions configures the Broker returned by NewJsonBroker. The zero value
+// produces the same output as JsonBroker.
+type JsonOptions struct {
+	// Envelope wraps the body in an object under the key "data", next to a
+	// "meta" object describing the controller, action, verb, and status, and
+	// for a *Page, the pagination.
+	Envelope bool
+	// KeyCase rewrites the keys of every object in the output.
+	KeyCase KeyCase
+	// UnescapedHtml turns off the escaping of <, >, and & in strings.
+	UnescapedHtml bool
+	// Jsonp names a request parameter that, when present, causes the output to
+	// be wrapped in a call to the JavaScript function it names. Because the
+	// response is then JavaScript, a Broker with this option should be
+	// registered for MIME types like "application/javascript".
+	Jsonp string
+}
+
+// NewJsonBroker returns a Broker that serializes bodies as JSON according to
+// opts.
+//
+// 	app.Accept("application/json").Via(gadget.NewJsonBroker(gadget.JsonOptions{
+// 		Envelope: true,
+// 		KeyCase:  gadget.SnakeCase,
+// 	}))
+func NewJsonBroker(opts JsonOptions) Broker {
+	return func(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+		return opts.serialize(r, status, body, data)
+	}
+}
+
 // JsonBroker attempts to transform an interface{} value into a JSON string.
 func JsonBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
-	var (
-		serialized []byte
-		err        error
-	)
+	return JsonOptions{}.serialize(r, status, body, data)
+}
+
+func (opts JsonOptions) serialize(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	if opts.Envelope {
+		meta := map[string]interface{}{"status": status}
+		if data != nil {
+			meta["controller"] = data.ControllerName
+			meta["action"] = data.Action
+			meta["verb"] = data.Verb
+		}
+		if page, ok := body.(*Page); ok {
+			meta["page"] = page.meta()
+			body = page.Items
+		}
+		body = map[string]interface{}{"data": body, "meta": meta}
+	}
+	if opts.KeyCase != KeepCase {
+		var err error
+		if body, err = recase(body, opts.KeyCase); err != nil {
+			return 500, ""
+		}
+	}
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(!opts.UnescapedHtml)
 	if env.Debug {
-		serialized, err = json.MarshalIndent(body, "", "  ")
-	} else {
-		serialized, err = json.Marshal(body)
+		encoder.SetIndent("", "  ")
 	}
-	if err != nil {
+	if err := encoder.Encode(body); err != nil {
 		return 500, ""
 	}
-	return status, string(serialized)
+	serialized := strings.TrimSuffix(buf.String(), "\n")
+	if callback := opts.callback(r); callback != "" {
+		serialized = fmt.Sprintf("/**/%s(%s);", callback, serialized)
+	}
+	return status, serialized
+}
+
+func (opts JsonOptions) callback(r *Request) string {
+	if opts.Jsonp == "" || r == nil {
+		return ""
+	}
+	callback, _ := r.Params[opts.Jsonp].(string)
+	if !jsonpCallback.MatchString(callback) {
+		return ""
+	}
+	return callback
+}
+
+// recase round-trips body through encoding/json so that struct tags and
+// MarshalJSON methods are honored, then rewrites the keys of the result.
+func recase(body interface{}, keyCase KeyCase) (interface{}, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return recaseValue(generic, keyCase), nil
+}
+
+func recaseValue(v interface{}, keyCase KeyCase) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		recased := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			recased[recaseKey(k, keyCase)] = recaseValue(inner, keyCase)
+		}
+		return recased
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = recaseValue(inner, keyCase)
+		}
+	}
+	return v
+}
+
+func recaseKey(k string, keyCase KeyCase) string {
+	switch keyCase {
+	case SnakeCase:
+		if strings.ToLower(k) == k {
+			return k
+		}
+		return strutil.Snakify(k)
+	case CamelCase:
+		return strutil.Camelize(k)
+	}
+	return k
 }
 
 // XmlBroker attempts to transform an interface{} value into a serialized XML string.
@@ -39,3 +174,134 @@ func XmlBroker(r *Request, status int, body interface{}, data *RouteData) (int,
 	}
 	return status, xml.Header + string(serialized)
 }
+
+// YamlBroker attempts to transform an interface{} value into a YAML document.
+func YamlBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	serialized, err := yaml.Marshal(body)
+	if err != nil {
+		return 500, ""
+	}
+	return status, string(serialized)
+}
+
+// MsgpackBroker attempts to transform an interface{} value into MessagePack.
+// Struct fields can be renamed with "msgpack" tags.
+func MsgpackBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	serialized, err := msgpack.Marshal(body)
+	if err != nil {
+		return 500, ""
+	}
+	return status, string(serialized)
+}
+
+// TextBroker renders an interface{} value as plain text. Values that implement
+// fmt.Stringer are rendered with their String method, and the elements of
+// slices and arrays are rendered one per line.
+func TextBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	v := reflect.ValueOf(body)
+	if _, ok := body.(fmt.Stringer); !ok && v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		lines := make([]string, v.Len())
+		for i := range lines {
+			lines[i] = textOf(v.Index(i).Interface())
+		}
+		return status, strings.Join(lines, "\n")
+	}
+	return status, textOf(body)
+}
+
+func textOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// CsvBroker transforms a slice of structs (or pointers to structs) into CSV
+// with a header row. A single struct is written as a one-row table. Columns
+// are named after the fields of the struct unless a "csv" tag supplies a
+// name; fields tagged `csv:"-"` and unexported fields are skipped. A
+// [][]string is written out as-is.
+func CsvBroker(r *Request, status int, body interface{}, data *RouteData) (int, string) {
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	if rows, ok := body.([][]string); ok {
+		writer.WriteAll(rows)
+	} else {
+		rows, err := csvRows(body)
+		if err != nil {
+			return 500, ""
+		}
+		writer.WriteAll(rows)
+	}
+	if writer.Error() != nil {
+		return 500, ""
+	}
+	return status, buf.String()
+}
+
+func csvRows(body interface{}) ([][]string, error) {
+	v := reflect.Indirect(reflect.ValueOf(body))
+	var records []reflect.Value
+	switch v.Kind() {
+	case reflect.Struct:
+		records = []reflect.Value{v}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			records = append(records, reflect.Indirect(v.Index(i)))
+		}
+	default:
+		return nil, fmt.Errorf("cannot write %T as CSV", body)
+	}
+	var t reflect.Type
+	if v.Kind() == reflect.Struct {
+		t = v.Type()
+	} else {
+		t = v.Type().Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot write %T as CSV", body)
+	}
+	var (
+		header []string
+		fields []int
+	)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("csv")
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+	rows := [][]string{header}
+	for _, record := range records {
+		row := make([]string, len(fields))
+		if record.IsValid() {
+			for j, i := range fields {
+				f := record.Field(i)
+				if f.Kind() == reflect.Ptr && f.IsNil() {
+					continue
+				}
+				value := f.Interface()
+				if _, ok := value.(fmt.Stringer); !ok {
+					value = reflect.Indirect(f).Interface()
+				}
+				row[j] = textOf(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,107 @@
+package gadget
+
+import (
+	"github.com/vmihailenco/msgpack"
+	. "launchpad.net/gocheck"
+)
+
+type SerializeSuite struct{}
+
+var _ = Suite(&SerializeSuite{})
+
+type recordLabel struct {
+	LabelName string
+	Founded   int `json:"founded_in"`
+	Website   string
+}
+
+//An enveloped JSON broker wraps the body in "data" and describes the route in "meta"
+func (s *SerializeSuite) TestEnvelope(c *C) {
+	broker := NewJsonBroker(JsonOptions{Envelope: true})
+	status, body := broker(&Request{}, 201, []int{1, 2}, &RouteData{ControllerName: "labels", Action: "create", Verb: "POST"})
+	c.Assert(status, Equals, 201)
+	c.Assert(body, Equals, `{"data":[1,2],"meta":{"action":"create","controller":"labels","status":201,"verb":"POST"}}`)
+}
+
+//SnakeCase rewrites struct field names but leaves keys that are already lower-cased alone
+func (s *SerializeSuite) TestSnakeCase(c *C) {
+	broker := NewJsonBroker(JsonOptions{KeyCase: SnakeCase})
+	_, body := broker(&Request{}, 200, &recordLabel{"Merge", 1989, "<merge>"}, &RouteData{})
+	c.Assert(body, Equals, `{"founded_in":1989,"label_name":"Merge","website":"\u003cmerge\u003e"}`)
+}
+
+//CamelCase rewrites both struct field names and snake-cased tags
+func (s *SerializeSuite) TestCamelCase(c *C) {
+	broker := NewJsonBroker(JsonOptions{KeyCase: CamelCase, UnescapedHtml: true})
+	_, body := broker(&Request{}, 200, []*recordLabel{{"Merge", 1989, "<merge>"}}, &RouteData{})
+	c.Assert(body, Equals, `[{"foundedIn":1989,"labelName":"Merge","website":"<merge>"}]`)
+}
+
+//The Jsonp option wraps output in the named callback only when the parameter is present and valid
+func (s *SerializeSuite) TestJsonp(c *C) {
+	broker := NewJsonBroker(JsonOptions{Jsonp: "callback"})
+	_, body := broker(&Request{Params: map[string]interface{}{"callback": "jQuery123.done"}}, 200, "hi", &RouteData{})
+	c.Assert(body, Equals, `/**/jQuery123.done("hi");`)
+	_, body = broker(&Request{Params: map[string]interface{}{"callback": "alert(1)//"}}, 200, "hi", &RouteData{})
+	c.Assert(body, Equals, `"hi"`)
+	_, body = broker(&Request{}, 200, "hi", &RouteData{})
+	c.Assert(body, Equals, `"hi"`)
+}
+
+type album struct {
+	Title   string `csv:"title" yaml:"title" msgpack:"title"`
+	Artist  *band  `csv:"artist" yaml:"artist" msgpack:"artist"`
+	Year    int    `csv:"year" yaml:"year" msgpack:"year"`
+	Catalog string `csv:"-" yaml:"-" msgpack:"-"`
+}
+
+type band struct {
+	Name string
+}
+
+func (b *band) String() string { return b.Name }
+
+//CsvBroker writes a header row from csv tags and a row per struct in a slice
+func (s *SerializeSuite) TestCsvBroker(c *C) {
+	albums := []*album{
+		{"On Avery Island", &band{"Neutral Milk Hotel"}, 1996, "MRG098"},
+		{"Tigermilk, Reissued", nil, 1996, "JPR001"},
+	}
+	status, body := CsvBroker(&Request{}, 200, albums, &RouteData{})
+	c.Assert(status, Equals, 200)
+	c.Assert(body, Equals, "title,artist,year\nOn Avery Island,Neutral Milk Hotel,1996\n\"Tigermilk, Reissued\",,1996\n")
+}
+
+//CsvBroker returns a 500 for bodies that aren't tabular
+func (s *SerializeSuite) TestCsvBrokerRejectsScalars(c *C) {
+	status, body := CsvBroker(&Request{}, 200, 42, &RouteData{})
+	c.Assert(status, Equals, 500)
+	c.Assert(body, Equals, "")
+}
+
+//YamlBroker honors yaml tags
+func (s *SerializeSuite) TestYamlBroker(c *C) {
+	status, body := YamlBroker(&Request{}, 200, map[string]int{"plays": 3}, &RouteData{})
+	c.Assert(status, Equals, 200)
+	c.Assert(body, Equals, "plays: 3\n")
+}
+
+//MsgpackBroker encodes values as MessagePack
+func (s *SerializeSuite) TestMsgpackBroker(c *C) {
+	status, body := MsgpackBroker(&Request{}, 200, &album{Title: "Foolish", Year: 1994}, &RouteData{})
+	c.Assert(status, Equals, 200)
+	var decoded map[string]interface{}
+	c.Assert(msgpack.Unmarshal([]byte(body), &decoded), IsNil)
+	c.Assert(decoded["title"], Equals, "Foolish")
+	c.Assert(decoded["year"], Equals, int64(1994))
+	_, ok := decoded["Catalog"]
+	c.Assert(ok, Equals, false)
+}
+
+//TextBroker uses String methods and puts slice elements on their own lines
+func (s *SerializeSuite) TestTextBroker(c *C) {
+	_, body := TextBroker(&Request{}, 200, &band{"Superchunk"}, &RouteData{})
+	c.Assert(body, Equals, "Superchunk")
+	_, body = TextBroker(&Request{}, 200, []*band{{"Superchunk"}, {"Polvo"}}, &RouteData{})
+	c.Assert(body, Equals, "Superchunk\nPolvo")
+}
@@ -0,0 +1,96 @@
+package gadget
+
+import (
+	"github.com/redneckbeard/gadget/env"
+	"github.com/redneckbeard/gadget/session"
+	"net/http"
+	"time"
+)
+
+var (
+	sessionStore session.Store
+	// SessionCookie is the template for the cookie that carries the
+	// session token; its Value, Expires and MaxAge are set by Gadget.
+	SessionCookie = http.Cookie{
+		Name:     "gadget_session",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	// SessionMaxAge is how long a session lasts after it was last changed.
+	SessionMaxAge = 14 * 24 * time.Hour
+)
+
+// StoreSessionsWith turns on sessions, persisting them in store. Sessions are
+// loaded the first time Request.Session is called and saved, with a fresh
+// cookie, when the response is written if they have changed.
+//
+// 	gadget.StoreSessionsWith(session.NewCookieStore([]byte(os.Getenv("SESSION_KEY"))))
+func StoreSessionsWith(store session.Store) {
+	sessionStore = store
+}
+
+// Session returns the session for the client making the request, creating an
+// empty one if the client has none or sent an invalid or expired token. It
+// panics if StoreSessionsWith has not been called.
+func (r *Request) Session() *session.Session {
+	if r.session != nil {
+		return r.session
+	}
+	if sessionStore == nil {
+		panic("Sessions must be configured with StoreSessionsWith before use")
+	}
+	if cookie, err := r.Cookie(SessionCookie.Name); err == nil {
+		if sess, err := sessionStore.Load(cookie.Value); err == nil {
+			r.session = sess
+			return sess
+		}
+	}
+	r.session = session.New()
+	return r.session
+}
+
+// saveSession persists a changed session and adds its cookie to response.
+func (r *Request) saveSession(response *Response) {
+	sess := r.session
+	if sess == nil || sessionStore == nil || !sess.Dirty() {
+		return
+	}
+	cookie := SessionCookie
+	if sess.Destroyed() {
+		if err := sessionStore.Delete(sess); err != nil {
+			env.Log("Unable to delete session: ", err)
+		}
+		cookie.MaxAge = -1
+		response.AddCookie(&cookie)
+		return
+	}
+	sess.Expires = time.Now().Add(SessionMaxAge)
+	token, err := sessionStore.Save(sess)
+	if err != nil {
+		env.Log("Unable to save session: ", err)
+		return
+	}
+	cookie.Value = token
+	cookie.Expires = sess.Expires
+	response.AddCookie(&cookie)
+}
+
+// SessionUser returns a UserIdentifier for IdentifyUsersWith that looks up
+// the value stored in the session under key with lookup. Requests whose
+// sessions have no such value, or for which lookup returns nil, get an
+// AnonymousUser.
+//
+// 	gadget.IdentifyUsersWith(gadget.SessionUser("user_id", func(id interface{}) gadget.User {
+// 		return models.FindUser(id.(int))
+// 	}))
+func SessionUser(key string, lookup func(id interface{}) User) UserIdentifier {
+	return func(r *Request) User {
+		if id := r.Session().Get(key); id != nil {
+			if user := lookup(id); user != nil {
+				return user
+			}
+		}
+		return &AnonymousUser{}
+	}
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// MaxCookieSize is the longest token a CookieStore will produce. Browsers
+// commonly refuse cookies larger than 4096 bytes, name and attributes
+// included.
+const MaxCookieSize = 3800
+
+// CookieStore keeps sessions entirely in the client's cookie, signed with
+// HMAC-SHA256 so that they can't be tampered with and, if created with
+// NewEncryptedCookieStore, encrypted with AES-GCM so that they can't be read.
+//
+// New tokens are always produced with the first key. Tokens produced with any
+// of the other keys are still accepted, so keys can be rotated by adding a new
+// key to the front of the list and dropping the oldest one once every
+// session made with it has expired.
+type CookieStore struct {
+	keys    [][]byte
+	encrypt bool
+}
+
+// NewCookieStore returns a CookieStore that signs sessions with keys.
+func NewCookieStore(keys ...[]byte) *CookieStore {
+	if len(keys) == 0 {
+		panic("NewCookieStore requires at least one key")
+	}
+	return &CookieStore{keys: keys}
+}
+
+// NewEncryptedCookieStore returns a CookieStore that encrypts and signs
+// sessions with keys.
+func NewEncryptedCookieStore(keys ...[]byte) *CookieStore {
+	s := NewCookieStore(keys...)
+	s.encrypt = true
+	return s
+}
+
+var encoding = base64.RawURLEncoding
+
+// Load verifies and decodes a token produced by Save.
+func (s *CookieStore) Load(token string) (*Session, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalid
+	}
+	body, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	mac, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	for _, key := range s.keys {
+		if !hmac.Equal(mac, sign(key, body)) {
+			continue
+		}
+		if s.encrypt {
+			if body, err = decrypt(key, body); err != nil {
+				return nil, ErrInvalid
+			}
+		}
+		return decode(body, "")
+	}
+	return nil, ErrInvalid
+}
+
+// Save encodes the session into a token. It returns an error if the token
+// would be longer than MaxCookieSize.
+func (s *CookieStore) Save(sess *Session) (string, error) {
+	body, err := encode(sess)
+	if err != nil {
+		return "", err
+	}
+	key := s.keys[0]
+	if s.encrypt {
+		if body, err = encrypt(key, body); err != nil {
+			return "", err
+		}
+	}
+	token := encoding.EncodeToString(body) + "." + encoding.EncodeToString(sign(key, body))
+	if len(token) > MaxCookieSize {
+		return "", errors.New("session: too large to store in a cookie")
+	}
+	return token, nil
+}
+
+// Delete is a no-op for CookieStore; the session disappears with the cookie.
+func (s *CookieStore) Delete(sess *Session) error { return nil }
+
+func sign(key, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func gcm(key []byte) (cipher.AEAD, error) {
+	// The AES key is derived from the signing key so that a single secret
+	// serves for both.
+	derived := sha256.Sum256(append([]byte("gadget-session-encryption:"), key...))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,152 @@
+/*
+Package session provides the Session type behind gadget.Request.Session and
+the stores that persist sessions between requests. Any type that satisfies
+Store can be passed to gadget.StoreSessionsWith; this package ships a store
+that keeps sessions in signed (and optionally encrypted) cookies, an in-memory
+store, and a file-backed store.
+
+Session values are encoded with encoding/gob, so values of types other than
+the basic ones must be registered with gob.Register before they are stored.
+*/
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrInvalid is returned by a Store's Load method when the token it was given
+// is malformed, has been tampered with, or belongs to an expired session.
+var ErrInvalid = errors.New("session: invalid or expired token")
+
+// Session holds values that persist across requests from the same client.
+type Session struct {
+	// ID identifies the session in server-side stores. It is empty for
+	// sessions kept entirely in cookies.
+	ID      string
+	Values  map[string]interface{}
+	Expires time.Time
+
+	dirty, rotate, destroyed bool
+}
+
+// New returns an empty Session.
+func New() *Session {
+	return &Session{Values: make(map[string]interface{})}
+}
+
+// Get returns the value stored under key, or nil.
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+// Set stores value under key.
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.dirty = true
+}
+
+// Delete removes the value stored under key.
+func (s *Session) Delete(key string) {
+	if _, ok := s.Values[key]; ok {
+		delete(s.Values, key)
+		s.dirty = true
+	}
+}
+
+// Clear removes all values from the session.
+func (s *Session) Clear() {
+	s.Values = make(map[string]interface{})
+	s.dirty = true
+}
+
+// Rotate gives the session a new ID when it is next saved, keeping its
+// values. Call it whenever a user logs in or out, so that a session ID planted
+// before login can't be used to hijack the session afterward.
+func (s *Session) Rotate() {
+	s.rotate = true
+	s.dirty = true
+}
+
+// Destroy removes the session from its store and the client when the
+// response is written.
+func (s *Session) Destroy() {
+	s.Clear()
+	s.destroyed = true
+}
+
+// Dirty reports whether the session has changed since it was loaded.
+func (s *Session) Dirty() bool { return s.dirty }
+
+// Destroyed reports whether Destroy has been called.
+func (s *Session) Destroyed() bool { return s.destroyed }
+
+// Expired reports whether the session's expiry time has passed.
+func (s *Session) Expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+// assignID gives the session an ID if it has none or is due for rotation,
+// returning the ID it replaces.
+func (s *Session) assignID() (old string) {
+	if s.ID == "" || s.rotate {
+		old = s.ID
+		s.ID = newID()
+		s.rotate = false
+	}
+	return
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// Store is the interface that session backends implement. Save returns the
+// token that the client should send back to have the session loaded again;
+// Load returns ErrInvalid for tokens that don't correspond to a current
+// session. Implementations must be safe for concurrent use.
+type Store interface {
+	Load(token string) (*Session, error)
+	Save(s *Session) (token string, err error)
+	Delete(s *Session) error
+}
+
+type record struct {
+	Values  map[string]interface{}
+	Expires time.Time
+}
+
+func encode(s *Session) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(&record{s.Values, s.Expires})
+	return buf.Bytes(), err
+}
+
+func decode(raw []byte, id string) (*Session, error) {
+	rec := &record{}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(rec); err != nil {
+		return nil, ErrInvalid
+	}
+	s := &Session{ID: id, Values: rec.Values, Expires: rec.Expires}
+	if s.Values == nil {
+		s.Values = make(map[string]interface{})
+	}
+	if s.Expired() {
+		return nil, ErrInvalid
+	}
+	return s, nil
+}
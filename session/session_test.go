@@ -0,0 +1,141 @@
+package session
+
+import (
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StoreSuite struct {
+	stores map[string]Store
+	dir    string
+}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "gadget-session")
+	c.Assert(err, IsNil)
+	s.dir = dir
+	fs, err := NewFileStore(dir)
+	c.Assert(err, IsNil)
+	s.stores = map[string]Store{
+		"cookie":    NewCookieStore([]byte("secret")),
+		"encrypted": NewEncryptedCookieStore([]byte("secret")),
+		"memory":    NewMemoryStore(),
+		"file":      fs,
+	}
+}
+
+func (s *StoreSuite) TearDownTest(c *C) {
+	os.RemoveAll(s.dir)
+}
+
+//Stores load what they saved
+func (s *StoreSuite) TestSaveLoad(c *C) {
+	for name, store := range s.stores {
+		sess := New()
+		sess.Set("user_id", 42)
+		sess.Expires = time.Now().Add(time.Hour)
+		token, err := store.Save(sess)
+		c.Assert(err, IsNil, Commentf(name))
+		loaded, err := store.Load(token)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Assert(loaded.Get("user_id"), Equals, 42, Commentf(name))
+		c.Assert(loaded.Dirty(), Equals, false, Commentf(name))
+	}
+}
+
+//Stores reject unknown, tampered and expired tokens
+func (s *StoreSuite) TestInvalidTokens(c *C) {
+	for name, store := range s.stores {
+		_, err := store.Load("../../etc/passwd")
+		c.Assert(err, Equals, ErrInvalid, Commentf(name))
+
+		sess := New()
+		sess.Set("a", "b")
+		sess.Expires = time.Now().Add(-time.Second)
+		token, err := store.Save(sess)
+		c.Assert(err, IsNil, Commentf(name))
+		_, err = store.Load(token)
+		c.Assert(err, Equals, ErrInvalid, Commentf(name))
+	}
+	store := s.stores["cookie"]
+	sess := New()
+	sess.Set("admin", false)
+	token, _ := store.Save(sess)
+	_, err := store.Load("x" + token[1:])
+	c.Assert(err, Equals, ErrInvalid)
+}
+
+//Rotating a server-side session changes its ID and forgets the old one
+func (s *StoreSuite) TestRotate(c *C) {
+	for _, name := range []string{"memory", "file"} {
+		store := s.stores[name]
+		sess := New()
+		sess.Set("a", "b")
+		old, _ := store.Save(sess)
+		sess.Rotate()
+		token, err := store.Save(sess)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Assert(token, Not(Equals), old, Commentf(name))
+		_, err = store.Load(old)
+		c.Assert(err, Equals, ErrInvalid, Commentf(name))
+		loaded, err := store.Load(token)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Assert(loaded.Get("a"), Equals, "b", Commentf(name))
+	}
+}
+
+//Deleted server-side sessions can't be loaded
+func (s *StoreSuite) TestDelete(c *C) {
+	for _, name := range []string{"memory", "file"} {
+		store := s.stores[name]
+		sess := New()
+		token, _ := store.Save(sess)
+		c.Assert(store.Delete(sess), IsNil, Commentf(name))
+		_, err := store.Load(token)
+		c.Assert(err, Equals, ErrInvalid, Commentf(name))
+	}
+}
+
+//Cookie stores accept tokens signed with older keys but sign with the first
+func (s *StoreSuite) TestKeyRotation(c *C) {
+	old := NewEncryptedCookieStore([]byte("old"))
+	sess := New()
+	sess.Set("a", "b")
+	token, _ := old.Save(sess)
+
+	rotated := NewEncryptedCookieStore([]byte("new"), []byte("old"))
+	loaded, err := rotated.Load(token)
+	c.Assert(err, IsNil)
+	c.Assert(loaded.Get("a"), Equals, "b")
+	fresh, _ := rotated.Save(loaded)
+	_, err = old.Load(fresh)
+	c.Assert(err, Equals, ErrInvalid)
+
+	_, err = NewEncryptedCookieStore([]byte("new")).Load(token)
+	c.Assert(err, Equals, ErrInvalid)
+}
+
+//Encrypted cookie tokens don't reveal their values
+func (s *StoreSuite) TestEncryption(c *C) {
+	sess := New()
+	sess.Set("secret", "swordfish")
+	token, _ := s.stores["encrypted"].Save(sess)
+	raw, _ := encoding.DecodeString(strings.Split(token, ".")[0])
+	c.Assert(strings.Contains(string(raw), "swordfish"), Equals, false)
+}
+
+//Cookie stores refuse sessions too large for a cookie
+func (s *StoreSuite) TestCookieTooLarge(c *C) {
+	sess := New()
+	sess.Set("big", strings.Repeat("x", MaxCookieSize))
+	_, err := s.stores["cookie"].Save(sess)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	raw     []byte
+	expires time.Time
+}
+
+// MemoryStore keeps sessions in memory, so they are lost when the process
+// exits and aren't shared between processes. The client's cookie carries
+// only the session ID.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Load returns the session with the ID token.
+func (s *MemoryStore) Load(token string) (*Session, error) {
+	s.mu.Lock()
+	entry, ok := s.sessions[token]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrInvalid
+	}
+	sess, err := decode(entry.raw, token)
+	if err != nil {
+		s.mu.Lock()
+		delete(s.sessions, token)
+		s.mu.Unlock()
+	}
+	return sess, err
+}
+
+// Save stores the session and returns its ID.
+func (s *MemoryStore) Save(sess *Session) (string, error) {
+	raw, err := encode(sess)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old := sess.assignID(); old != "" {
+		delete(s.sessions, old)
+	}
+	s.sessions[sess.ID] = memoryEntry{raw, sess.Expires}
+	return sess.ID, nil
+}
+
+// Delete removes the session.
+func (s *MemoryStore) Delete(sess *Session) error {
+	s.mu.Lock()
+	delete(s.sessions, sess.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// Sweep removes expired sessions. Applications using a MemoryStore should
+// call it periodically.
+func (s *MemoryStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, entry := range s.sessions {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Len returns the number of sessions in the store.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// FileStore keeps each session in its own file in a directory, named by the
+// session ID.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that writes to dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// Load reads the session with the ID token.
+func (s *FileStore) Load(token string) (*Session, error) {
+	if !validID(token) {
+		return nil, ErrInvalid
+	}
+	raw, err := ioutil.ReadFile(s.path(token))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	sess, err := decode(raw, token)
+	if err != nil {
+		os.Remove(s.path(token))
+	}
+	return sess, err
+}
+
+// Save writes the session and returns its ID.
+func (s *FileStore) Save(sess *Session) (string, error) {
+	raw, err := encode(sess)
+	if err != nil {
+		return "", err
+	}
+	if old := sess.assignID(); old != "" && validID(old) {
+		os.Remove(s.path(old))
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	tmp.Close()
+	return sess.ID, os.Rename(tmp.Name(), s.path(sess.ID))
+}
+
+// Delete removes the session's file.
+func (s *FileStore) Delete(sess *Session) error {
+	if !validID(sess.ID) {
+		return nil
+	}
+	err := os.Remove(s.path(sess.ID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package gadget
+
+import (
+	"fmt"
+	"github.com/redneckbeard/gadget/session"
+	. "launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type SessionSuite struct{}
+
+type sessionApp struct {
+	*App
+}
+
+var sa *sessionApp
+
+var _ = Suite(&SessionSuite{})
+
+func (s *SessionSuite) SetUpTest(c *C) {
+	sa = &sessionApp{&App{}}
+	sa.Register(&CartController{})
+	sa.Routes(sa.Resource("carts"))
+	StoreSessionsWith(session.NewMemoryStore())
+}
+
+func (s *SessionSuite) TearDownTest(c *C) {
+	sa.Controllers = make(map[string]Controller)
+	StoreSessionsWith(nil)
+	clearUserIdentifier()
+}
+
+type CartController struct {
+	*DefaultController
+}
+
+func (c *CartController) Index(r *Request) (int, interface{}) {
+	return 200, fmt.Sprint(r.Session().Get("items"))
+}
+
+func (c *CartController) Create(r *Request) (int, interface{}) {
+	sess := r.Session()
+	items, _ := sess.Get("items").(int)
+	sess.Set("items", items+1)
+	return 201, ""
+}
+
+func (c *CartController) Destroy(r *Request) (int, interface{}) {
+	r.Session().Destroy()
+	return 204, ""
+}
+
+func (c *CartController) Login(r *Request) (int, interface{}) {
+	r.Session().Set("user_id", 7)
+	r.Session().Rotate()
+	return 302, "/carts"
+}
+
+func (c *CartController) Whoami(r *Request) (int, interface{}) {
+	user, ok := r.User().(*sessionUser)
+	if !ok {
+		return 200, "anonymous"
+	}
+	return 200, fmt.Sprint(user.id)
+}
+
+type sessionUser struct {
+	id int
+}
+
+func (u *sessionUser) Authenticated() bool { return true }
+
+func (s *SessionSuite) do(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/"+path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	sa.Handler()(resp, req)
+	return resp
+}
+
+func sessionCookie(resp *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range (&http.Response{Header: resp.Header()}).Cookies() {
+		if cookie.Name == SessionCookie.Name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+//Changed sessions are saved and sent back in a cookie
+func (s *SessionSuite) TestSessionPersists(c *C) {
+	resp := s.do("POST", "carts", nil)
+	cookie := sessionCookie(resp)
+	c.Assert(cookie, NotNil)
+	c.Assert(cookie.HttpOnly, Equals, true)
+	resp = s.do("POST", "carts", cookie)
+	cookie = sessionCookie(resp)
+	c.Assert(s.do("GET", "carts", cookie).Body.String(), Equals, "2")
+}
+
+//Unchanged sessions don't set cookies
+func (s *SessionSuite) TestUnchangedSession(c *C) {
+	cookie := sessionCookie(s.do("POST", "carts", nil))
+	c.Assert(sessionCookie(s.do("GET", "carts", cookie)), IsNil)
+	c.Assert(sessionCookie(s.do("GET", "carts", nil)), IsNil)
+}
+
+//Destroyed sessions are removed from the store and the client
+func (s *SessionSuite) TestDestroy(c *C) {
+	cookie := sessionCookie(s.do("POST", "carts", nil))
+	expired := sessionCookie(s.do("DELETE", "carts/1", cookie))
+	c.Assert(expired.MaxAge, Equals, -1)
+	c.Assert(s.do("GET", "carts", cookie).Body.String(), Equals, "<nil>")
+}
+
+//Sessions are saved on redirects, and rotation issues a new token
+func (s *SessionSuite) TestRotateOnRedirect(c *C) {
+	cookie := sessionCookie(s.do("POST", "carts", nil))
+	rotated := sessionCookie(s.do("GET", "carts/login", cookie))
+	c.Assert(rotated, NotNil)
+	c.Assert(rotated.Value, Not(Equals), cookie.Value)
+	c.Assert(s.do("GET", "carts", cookie).Body.String(), Equals, "<nil>")
+	c.Assert(s.do("GET", "carts", rotated).Body.String(), Equals, "1")
+}
+
+//SessionUser identifies users from a session value
+func (s *SessionSuite) TestSessionUser(c *C) {
+	IdentifyUsersWith(SessionUser("user_id", func(id interface{}) User {
+		return &sessionUser{id.(int)}
+	}))
+	c.Assert(s.do("GET", "carts/whoami", nil).Body.String(), Equals, "anonymous")
+	cookie := sessionCookie(s.do("GET", "carts/login", nil))
+	c.Assert(s.do("GET", "carts/whoami", cookie).Body.String(), Equals, "7")
+}
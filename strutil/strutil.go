@@ -48,3 +48,22 @@ func Hyphenate(pascal string) string {
 func Snakify(pascal string) string {
 	return depascal(pascal, "_")
 }
+
+// Camelize converts a Pascal-cased, snake-cased, or hyphen-separated string to
+// a camel-cased string.
+func Camelize(s string) string {
+	var words []string
+	if strings.ContainsAny(s, "_-") {
+		words = strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' })
+	} else {
+		words = strings.Split(Snakify(s), "_")
+	}
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 && word != "" {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
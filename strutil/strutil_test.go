@@ -26,3 +26,23 @@ func TestSnakify(t *testing.T) {
 		}
 	}
 }
+
+func TestCamelize(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{in: "Product", out: "product"},
+		{in: "SpecialGuest", out: "specialGuest"},
+		{in: "special_guest", out: "specialGuest"},
+		{in: "special-guest", out: "specialGuest"},
+		{in: "specialGuest", out: "specialGuest"},
+		{in: "UserID", out: "userId"},
+		{in: "HTMLTidy", out: "htmlTidy"},
+	}
+	for _, c := range cases {
+		if Camelize(c.in) != c.out {
+			t.Fail()
+			t.Log(c.in, "=>", Camelize(c.in))
+		}
+	}
+}
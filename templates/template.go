@@ -47,6 +47,26 @@ func loadWithRootFallback(templateName, controllerName string, helpers template.
 // the Show method of a FavoriteController would look for a template
 // "templates/favorites/show.html."
 //
+// When body is a *gadget.Page, the "page" helper returns it from anywhere in
+// the template tree, so that subtemplates can link to {{page.PrevUrl}} and
+// {{page.NextUrl}}.
+//
+// The "flashes" helper returns the messages added with Request.Flash during
+// the previous request, optionally only those of the kinds passed to it:
+//
+// 	{{range flashes "notice" "error"}}<p class="{{.Kind}}">{{.Message}}</p>{{end}}
+//
+// The "csrf_token" helper returns the token that forms must submit when
+// gadget.CsrfProtection is on:
+//
+// 	<input type="hidden" name="csrf_token" value="{{csrf_token}}">
+//
+// The "csp_nonce" helper returns the nonce that a Content-Security-Policy
+// containing "{nonce}" (see gadget.SecurityHeaders) allows inline scripts and
+// styles with:
+//
+// 	<script nonce="{{csp_nonce}}">initialize();</script>
+//
 // All error codes can also be served via their own templates. Non-200 statuses
 // will result in TemplateBroker looking for a "templates/403.html",
 // "templates/502.html", etc.
@@ -55,6 +75,19 @@ func TemplateBroker(r *gadget.Request, status int, body interface{}, data *gadge
 	helpers["request"] = func() *gadget.Request {
 		return r
 	}
+	helpers["page"] = func() *gadget.Page {
+		page, _ := body.(*gadget.Page)
+		return page
+	}
+	helpers["flashes"] = func(kinds ...string) []gadget.Flash {
+		return r.Flashes(kinds...)
+	}
+	helpers["csrf_token"] = func() string {
+		return r.CsrfToken()
+	}
+	helpers["csp_nonce"] = func() string {
+		return r.CspNonce()
+	}
 	helpers["render"] = func(templateName string, context interface{}) template.HTML {
 		var (
 			t   *template.Template
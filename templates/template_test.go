@@ -1,8 +1,12 @@
 package templates
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"github.com/redneckbeard/gadget"
+	"github.com/redneckbeard/gadget/session"
 	. "launchpad.net/gocheck"
+	"net/http"
 	"strings"
 	"testing"
 )
@@ -49,3 +53,46 @@ func (s *TemplateSuite) TestMissingDefine(c *C) {
 	c.Assert(status, Equals, 200)
 	c.Assert(strings.TrimSpace(body), Equals, context)
 }
+
+//The "page" helper exposes a *gadget.Page body to subtemplates
+func (s *TemplateSuite) TestPageHelper(c *C) {
+	TemplatePath = "testdata/page"
+	page := &gadget.Page{Number: 1, PerPage: 2, Total: 3, Items: []string{"a", "b"}}
+	status, body := TemplateBroker(&gadget.Request{}, 200, page, &gadget.RouteData{"widgets", "index", "GET"})
+	c.Assert(status, Equals, 200)
+	c.Assert(strings.TrimSpace(body), Equals, `a b <a href="/?page=2&amp;per_page=2">next</a>`)
+}
+
+//The "flashes" helper returns the flash messages from the previous request
+func (s *TemplateSuite) TestFlashesHelper(c *C) {
+	TemplatePath = "testdata/flash"
+	raw, _ := http.NewRequest("GET", "http://127.0.0.1:8000/widgets", nil)
+	flashes, _ := json.Marshal([]gadget.Flash{{Kind: "notice", Message: "Saved <b>"}, {Kind: "error", Message: "Oops"}})
+	raw.AddCookie(&http.Cookie{Name: gadget.FlashCookie.Name, Value: base64.RawURLEncoding.EncodeToString(flashes)})
+	status, body := TemplateBroker(&gadget.Request{Request: raw}, 200, nil, &gadget.RouteData{"widgets", "index", "GET"})
+	c.Assert(status, Equals, 200)
+	c.Assert(strings.TrimSpace(body), Equals, `<p class="notice">Saved &lt;b&gt;</p>2`)
+}
+
+//The "csrf_token" helper returns a CSRF token for the request's session
+func (s *TemplateSuite) TestCsrfTokenHelper(c *C) {
+	gadget.StoreSessionsWith(session.NewMemoryStore())
+	defer gadget.StoreSessionsWith(nil)
+	TemplatePath = "testdata/csrf"
+	raw, _ := http.NewRequest("GET", "http://127.0.0.1:8000/widgets", nil)
+	r := &gadget.Request{Request: raw}
+	status, body := TemplateBroker(r, 200, nil, &gadget.RouteData{"widgets", "index", "GET"})
+	c.Assert(status, Equals, 200)
+	c.Assert(strings.TrimSpace(body), Matches, `<input type="hidden" name="csrf_token" value="[\w-]{86}">`)
+	c.Assert(r.Session().Dirty(), Equals, true)
+}
+
+//The "csp_nonce" helper returns the request's CspNonce
+func (s *TemplateSuite) TestCspNonceHelper(c *C) {
+	TemplatePath = "testdata/csp"
+	raw, _ := http.NewRequest("GET", "http://127.0.0.1:8000/widgets", nil)
+	r := &gadget.Request{Request: raw}
+	status, body := TemplateBroker(r, 200, nil, &gadget.RouteData{"widgets", "index", "GET"})
+	c.Assert(status, Equals, 200)
+	c.Assert(strings.TrimSpace(body), Equals, `<script nonce="`+r.CspNonce()+`">go();</script>`)
+}
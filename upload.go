@@ -0,0 +1,44 @@
+package gadget
+
+import (
+	"errors"
+	"mime/multipart"
+)
+
+// MultipartMemory is how much of a multipart/form-data body is kept in memory.
+// Uploaded files beyond it are written to temporary files, which are removed
+// once the response has been written.
+var MultipartMemory int64 = 1 << 20
+
+// Files holds the files uploaded in a multipart/form-data request, keyed by
+// form field name.
+type Files map[string][]*multipart.FileHeader
+
+// Get returns the first file uploaded under name, or nil if there is none.
+func (f Files) Get(name string) *multipart.FileHeader {
+	if files := f[name]; len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
+
+// All returns every file uploaded under name.
+func (f Files) All(name string) []*multipart.FileHeader {
+	return f[name]
+}
+
+// Open opens the first file uploaded under name.
+func (f Files) Open(name string) (multipart.File, error) {
+	header := f.Get(name)
+	if header == nil {
+		return nil, errors.New("No file uploaded as " + name)
+	}
+	return header.Open()
+}
+
+// removeTempFiles deletes any temporary files created for uploads.
+func (r *Request) removeTempFiles() {
+	if r.MultipartForm != nil {
+		r.MultipartForm.RemoveAll()
+	}
+}
@@ -0,0 +1,107 @@
+package gadget
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type UploadSuite struct{}
+
+type uploadApp struct {
+	*App
+}
+
+var ua *uploadApp
+
+var _ = Suite(&UploadSuite{})
+
+func (s *UploadSuite) SetUpSuite(c *C) {
+	ua = &uploadApp{&App{}}
+	ctlr := &PhotoController{}
+	ua.Register(ctlr)
+	ctlr.LimitBodySize(1024, "update")
+	ua.Accept("application/json").Via(JsonBroker)
+	ua.Routes(ua.Resource("photos"))
+}
+
+func (s *UploadSuite) TearDownSuite(c *C) {
+	ua.Controllers = make(map[string]Controller)
+}
+
+type PhotoController struct {
+	*DefaultController
+}
+
+func (c *PhotoController) Create(r *Request) (int, interface{}) {
+	f, err := r.Files.Open("photo")
+	if err != nil {
+		return 400, err.Error()
+	}
+	defer f.Close()
+	content, _ := ioutil.ReadAll(f)
+	return 201, fmt.Sprintf("%s %s %s %d", r.Params["caption"], r.Files.Get("photo").Filename, content, len(r.Files.All("photo")))
+}
+
+func (c *PhotoController) Update(r *Request) (int, interface{}) {
+	return 200, fmt.Sprint(r.Params["caption"])
+}
+
+func uploadRequest(c *C, method, path string, size int) *http.Request {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	w.WriteField("caption", "sunset")
+	part, err := w.CreateFormFile("photo", "sunset.jpg")
+	c.Assert(err, IsNil)
+	part.Write([]byte(strings.Repeat("x", size)))
+	w.Close()
+	req, _ := http.NewRequest(method, "http://127.0.0.1:8000/"+path, buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+//Uploaded files are available through Request.Files
+func (s *UploadSuite) TestFiles(c *C) {
+	resp := httptest.NewRecorder()
+	ua.Handler()(resp, uploadRequest(c, "POST", "photos", 3))
+	c.Assert(resp.Code, Equals, 201)
+	c.Assert(resp.Body.String(), Equals, "sunset sunset.jpg xxx 1")
+}
+
+//Bodies over an action's size limit are refused with a 413
+func (s *UploadSuite) TestBodyTooLarge(c *C) {
+	resp := httptest.NewRecorder()
+	ua.Handler()(resp, uploadRequest(c, "PUT", "photos/1", 100))
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(resp.Body.String(), Equals, "sunset")
+
+	resp = httptest.NewRecorder()
+	ua.Handler()(resp, uploadRequest(c, "PUT", "photos/1", 2048))
+	c.Assert(resp.Code, Equals, 413)
+}
+
+//Bodies that exceed the limit without declaring a Content-Length are refused too
+func (s *UploadSuite) TestBodyTooLargeWithoutContentLength(c *C) {
+	req := uploadRequest(c, "PUT", "photos/1", 2048)
+	req.ContentLength = -1
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	ua.Handler()(resp, req)
+	c.Assert(resp.Code, Equals, 413)
+	c.Assert(resp.Header().Get("Content-Type"), Equals, "application/json")
+	c.Assert(resp.Body.String(), Matches, `.*"status":413.*`)
+}
+
+//Actions without a limit of their own use MaxBodySize
+func (s *UploadSuite) TestDefaultLimit(c *C) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 16
+	resp := httptest.NewRecorder()
+	ua.Handler()(resp, uploadRequest(c, "POST", "photos", 3))
+	c.Assert(resp.Code, Equals, 413)
+}
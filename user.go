@@ -21,9 +21,9 @@ type UserIdentifier func(*Request) User
 var identifyUser UserIdentifier
 
 // IdentifyUsersWith allows Gadget applications to register a UserIdentifier
-// function to be called when processing each incoming request. The return
-// value of the UserIdentifier will be set on the Request object if not nil;
-// AnonymousUser will be used otherwise.
+// function to be called the first time Request.User is called for a request.
+// The return value of the UserIdentifier is kept for the rest of the request
+// if not nil; AnonymousUser will be used otherwise.
 func IdentifyUsersWith(ui UserIdentifier) {
 	identifyUser = ui
 }
@@ -1,10 +1,13 @@
 package gadget
 
 import (
+	"fmt"
 	"io/ioutil"
 	. "launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"time"
 )
 
 type UserSuite struct{}
@@ -18,7 +21,12 @@ var u *userApp
 func (s *UserSuite) SetUpTest(c *C) {
 	u = &userApp{&App{}}
 	u.Register(&AuthStatusController{})
-	u.Routes(u.Resource("auth-status"))
+	u.Routes(
+		u.Resource("auth-status"),
+		u.HandleFunc("whoami", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, UserFor(r).Authenticated())
+		}),
+	)
 }
 
 func (s *UserSuite) TearDownTest(c *C) {
@@ -34,7 +42,15 @@ type AuthStatusController struct {
 func (c *AuthStatusController) Plural() string { return "auth-status" }
 
 func (c *AuthStatusController) Index(r *Request) (int, interface{}) {
-	return 200, r.User.Authenticated()
+	return 200, r.User().Authenticated()
+}
+
+func (c *AuthStatusController) Create(r *Request) (int, interface{}) {
+	return 200, r.Params["name"]
+}
+
+func (c *AuthStatusController) Show(r *Request) (int, interface{}) {
+	return 200, r.UrlParams["auth_status_id"]
 }
 
 type AuthedUser struct{}
@@ -87,3 +103,105 @@ func (s *UserSuite) TestUserAttachedToRequestAuthenticatedRegisteredUseridentifi
 	c.Assert(err, IsNil)
 	c.Assert(string(body), Equals, "true")
 }
+
+func countingAuth(calls *int) UserIdentifier {
+	return func(r *Request) User {
+		*calls++
+		return FakeAuth(r)
+	}
+}
+
+//The UserIdentifier should be called only once per request no matter how many times User is called
+func (s *UserSuite) TestUseridentifierCalledOnlyOncePerRequest(c *C) {
+	var calls int
+	IdentifyUsersWith(countingAuth(&calls))
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/auth-status?authed=yes", nil)
+	r := newRequest(req)
+	r.parseBody(MaxBodySize)
+	c.Assert(r.User().Authenticated(), Equals, true)
+	c.Assert(r.User().Authenticated(), Equals, true)
+	c.Assert(calls, Equals, 1)
+}
+
+//The UserIdentifier should not be called for actions that never ask for the User
+func (s *UserSuite) TestUseridentifierNotCalledForActionsThatNeverAskForUser(c *C) {
+	var calls int
+	IdentifyUsersWith(countingAuth(&calls))
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/auth-status/1", nil)
+	resp := httptest.NewRecorder()
+	u.Handler()(resp, req)
+	c.Assert(resp.Code, Equals, 200)
+	c.Assert(calls, Equals, 0)
+}
+
+//SetUser should replace the User for the rest of the request
+func (s *UserSuite) TestSetuserReplacesUserForRestOfRequest(c *C) {
+	IdentifyUsersWith(FakeAuth)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/auth-status", nil)
+	r := newRequest(req)
+	c.Assert(r.User().Authenticated(), Equals, false)
+	r.SetUser(&AuthedUser{})
+	c.Assert(r.User().Authenticated(), Equals, true)
+}
+
+//UserFor should identify the User for http.HandlerFuncs mounted with HandleFunc
+func (s *UserSuite) TestUserforIdentifiesUserForHandlefuncRoutes(c *C) {
+	IdentifyUsersWith(FakeAuth)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/whoami?authed=yes", nil)
+	resp := httptest.NewRecorder()
+	u.Handler()(resp, req)
+	c.Assert(resp.Body.String(), Equals, "true")
+}
+
+//Identifying a user from the request body before routing should leave the body for the action
+func (s *UserSuite) TestIdentifyingFromBodyBeforeRoutingLeavesBody(c *C) {
+	secret := []byte("secret")
+	IdentifyUsersWith(ApiKeyAuth(func(keyId string) []byte { return secret }))
+	u.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Assert(UserFor(r).Authenticated(), Equals, true)
+			h.ServeHTTP(w, r)
+		})
+	})
+	u.Routes(
+		u.Resource("auth-status"),
+		u.HandleFunc("echo", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		}),
+	)
+	for path, expected := range map[string]string{"auth-status": "widget", "echo": `{"name":"widget"}`} {
+		body := `{"name":"widget"}`
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8000/"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		SignRequest(req, "key1", secret, []byte(body))
+		resp := httptest.NewRecorder()
+		u.Handler()(resp, req)
+		c.Assert(resp.Body.String(), Equals, expected)
+	}
+}
+
+//The UserIdentifier should run once per request, even when middleware asks for the User
+func (s *UserSuite) TestUseridentifierRunsOncePerRequestWithMiddleware(c *C) {
+	var calls int
+	IdentifyUsersWith(func(r *Request) User {
+		calls++
+		return &AuthedUser{}
+	})
+	u.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			UserFor(r)
+			UserFor(r)
+			h.ServeHTTP(w, r)
+		})
+	})
+	u.Use(LimitRate(KeyByUser(func(User) string { return "jo" }), NewTokenBucket(10, time.Minute)).Handler)
+	for _, path := range []string{"auth-status", "whoami"} {
+		calls = 0
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8000/"+path, nil)
+		resp := httptest.NewRecorder()
+		u.Handler()(resp, req)
+		c.Assert(resp.Body.String(), Equals, "true")
+		c.Assert(calls, Equals, 1)
+	}
+}